@@ -0,0 +1,90 @@
+package socket
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// ServeAll serves the handler on every listener. The listeners share one
+// lifecycle: when the context is canceled they're all gracefully shutdown and
+// the first fatal error shuts down the rest.
+func ServeAll(ctx context.Context, listeners []net.Listener, handler http.Handler, options ...Option) error {
+	handlers := make([]http.Handler, len(listeners))
+	for i := range listeners {
+		handlers[i] = handler
+	}
+	return serveAll(ctx, listeners, handlers, options)
+}
+
+// ListenAndServeAll is a convenience function that listens on every address
+// and serves the handler with ServeAll. If any address fails to bind, the
+// listeners that were already opened are closed.
+func ListenAndServeAll(ctx context.Context, handler http.Handler, addrs []string, options ...Option) error {
+	listeners, err := listenAll(addrs)
+	if err != nil {
+		return err
+	}
+	return ServeAll(ctx, listeners, handler, options...)
+}
+
+// ListenAndServeMap is like ListenAndServeAll, but serves a different handler
+// on each address.
+func ListenAndServeMap(ctx context.Context, handlers map[string]http.Handler, options ...Option) error {
+	addrs := make([]string, 0, len(handlers))
+	hs := make([]http.Handler, 0, len(handlers))
+	for addr, handler := range handlers {
+		addrs = append(addrs, addr)
+		hs = append(hs, handler)
+	}
+	listeners, err := listenAll(addrs)
+	if err != nil {
+		return err
+	}
+	return serveAll(ctx, listeners, hs, options)
+}
+
+// listenAll listens on every address, closing them all if one fails
+func listenAll(addrs []string) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		ln, err := Listen(addr)
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// serveAll serves each handler on its listener until the context is canceled
+// or one of them fails. Reloading happens once for all of them.
+func serveAll(ctx context.Context, listeners []net.Listener, handlers []http.Handler, options []Option) error {
+	config := newConfig(options)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	handles := make([]*Handle, len(listeners))
+	for i, listener := range listeners {
+		handles[i] = start(ctx, listener, handlers[i], config, options)
+	}
+	if config.reload != nil {
+		reloadOnHangup(ctx, config, nil, handles...)
+	}
+	errs := make(chan error, len(handles))
+	for _, handle := range handles {
+		go func() {
+			errs <- handle.Wait()
+		}()
+	}
+	var first error
+	for range handles {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+			cancel()
+		}
+	}
+	return first
+}
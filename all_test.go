@@ -0,0 +1,149 @@
+package socket_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+func get(t testing.TB, addr string) string {
+	t.Helper()
+	is := is.New(t)
+	transport, err := socket.Transport(addr)
+	is.NoErr(err)
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   time.Second,
+	}
+	var res *http.Response
+	for attempts := 0; ; attempts++ {
+		if attempts > 5 {
+			is.Fail() // should have connected by now
+		}
+		res, err = client.Get("http://localhost")
+		if err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	is.NoErr(err)
+	return string(body)
+}
+
+func freeAddr(t testing.TB) string {
+	t.Helper()
+	ln, err := socket.Listen(":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func TestServeAll(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln1, err := socket.Listen(":0")
+	is.NoErr(err)
+	ln2, err := socket.Listen(filepath.Join(t.TempDir(), "test.sock"))
+	is.NoErr(err)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.ServeAll(ctx, []net.Listener{ln1, ln2}, handler) })
+	is.Equal(get(t, ln1.Addr().String()), "ok")
+	is.Equal(get(t, ln2.Addr().String()), "ok")
+	cancel()
+	is.NoErr(eg.Wait())
+	_, err = socket.Dial(context.Background(), ln1.Addr().String())
+	is.True(err != nil) // should have stopped
+	_, err = socket.Dial(context.Background(), ln2.Addr().String())
+	is.True(err != nil) // should have stopped
+}
+
+func TestListenAndServeMap(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tcp := freeAddr(t)
+	unix := filepath.Join(t.TempDir(), "admin.sock")
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.ListenAndServeMap(ctx, map[string]http.Handler{
+			tcp: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("public"))
+			}),
+			unix: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("admin"))
+			}),
+		})
+	})
+	is.Equal(get(t, tcp), "public")
+	is.Equal(get(t, unix), "admin")
+	cancel()
+	is.NoErr(eg.Wait())
+}
+
+func TestListenAndServeAllOptions(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tcp := freeAddr(t)
+	unix := filepath.Join(t.TempDir(), "test.sock")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.ListenAndServeAll(ctx, handler, []string{tcp, unix}, socket.WithRecover(nil))
+	})
+	// The options apply to every listener
+	is.Equal(get(t, tcp), "Internal Server Error\n")
+	is.Equal(get(t, unix), "Internal Server Error\n")
+	cancel()
+	is.NoErr(eg.Wait())
+}
+
+func TestListenAndServeAllBindError(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	defer ln.Close()
+	unix := filepath.Join(t.TempDir(), "test.sock")
+	// The second address is already taken
+	err = socket.ListenAndServeAll(context.Background(), http.NotFoundHandler(), []string{unix, ln.Addr().String()})
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "address already in use"))
+	// The unix socket should have been closed and cleaned up
+	ln2, err := socket.Listen(unix)
+	is.NoErr(err)
+	is.NoErr(ln2.Close())
+}
+
+func TestServeAllFatalError(t *testing.T) {
+	is := is.New(t)
+	ln1, err := socket.Listen(":0")
+	is.NoErr(err)
+	ln2, err := socket.Listen(":0")
+	is.NoErr(err)
+	// Closing a listener from underneath the server is fatal
+	is.NoErr(ln2.Close())
+	err = socket.ServeAll(context.Background(), []net.Listener{ln1, ln2}, http.NotFoundHandler())
+	is.True(err != nil)
+	// The other listener should have been shutdown
+	_, err = socket.Dial(context.Background(), ln1.Addr().String())
+	is.True(err != nil)
+}
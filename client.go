@@ -4,54 +4,98 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"net/url"
+	"syscall"
 	"time"
 )
 
-// Dial creates a connection to an address
+// defaultDialTimeout is how long dialing can take by default, also used as the
+// default keep-alive period
+const defaultDialTimeout = 30 * time.Second
+
+// DialConfig configures how connections are dialed. The zero value dials like
+// Dial. Socket options on the address are applied on top of the config.
+type DialConfig struct {
+	// Timeout for connecting. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	// LocalAddr to dial from, like a source IP with &net.TCPAddr{IP: ip} or a
+	// source port with &net.TCPAddr{Port: 4000}
+	LocalAddr net.Addr
+
+	// FallbackDelay is how long to wait for IPv6 before also trying IPv4. It
+	// works like net.Dialer.FallbackDelay.
+	FallbackDelay time.Duration
+
+	// Resolver looks up hosts instead of net.DefaultResolver
+	Resolver *net.Resolver
+
+	// SocketOptions tune the connection. Keep-alive probes are sent every 30
+	// seconds unless configured otherwise.
+	SocketOptions
+
+	// Control is called with the raw socket after the options above are set,
+	// like net.Dialer.Control
+	Control func(network, address string, c syscall.RawConn) error
+}
+
+// Dial creates a connection to an address. Adding ?proxy=v1 or ?proxy=v2 to
+// the address sends a PROXY protocol header after connecting. Socket options
+// like ?keepalive=10s or ?nodelay=0 are applied to the connection.
 func Dial(ctx context.Context, address string) (net.Conn, error) {
+	return new(DialConfig).Dial(ctx, address)
+}
+
+// Dial creates a connection to an address with the config applied
+func (c *DialConfig) Dial(ctx context.Context, address string) (net.Conn, error) {
 	url, err := Parse(address)
 	if err != nil {
 		return nil, err
 	}
-	dialer := &net.Dialer{
-		Timeout:   30 * time.Second,
-		KeepAlive: 30 * time.Second,
-	}
-	// Empty host means the path is a unix domain socket
-	if url.Host == "" {
-		return dialer.DialContext(ctx, "unix", address)
-	}
-	return dialer.DialContext(ctx, "tcp", url.Host)
+	return c.dial(ctx, url)
 }
 
 // Transport creates a RoundTripper for an HTTP Client
 func Transport(path string) (*http.Transport, error) {
+	return new(DialConfig).Transport(path)
+}
+
+// Transport creates a RoundTripper for an HTTP Client that dials with the
+// config applied
+func (c *DialConfig) Transport(path string) (*http.Transport, error) {
 	url, err := Parse(path)
 	if err != nil {
 		return nil, err
 	}
-	// Empty host means the path is a unix domain socket
-	if url.Host == "" {
-		dialer := new(net.Dialer)
+	// Validate the query upfront rather than on every dial
+	if _, err := proxyVersion(url.Query()); err != nil {
+		return nil, err
+	}
+	if _, err := c.withQuery(url.Query()); err != nil {
+		return nil, err
+	}
+	// File descriptors hold a single connection
+	if url.Scheme == "fd" {
+		return c.fdTransport(url), nil
+	}
+	// Empty host means the path is a unix domain socket. In-memory listeners
+	// don't go through proxies either.
+	if url.Host == "" || url.Scheme == "mem" {
 		return &http.Transport{
 			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
-				return dialer.DialContext(ctx, "unix", path)
+				return c.dial(ctx, url)
 			},
 		}, nil
 	}
-	return httpTransport(url.Host), nil
+	return c.httpTransport(url), nil
 }
 
 // httpTransport is a modified from http.DefaultTransport
-func httpTransport(host string) *http.Transport {
-	dialer := &net.Dialer{
-		Timeout:   30 * time.Second,
-		KeepAlive: 30 * time.Second,
-	}
+func (c *DialConfig) httpTransport(url *url.URL) *http.Transport {
 	return &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return dialer.DialContext(ctx, network, host)
+			return c.dial(ctx, url)
 		},
 		ForceAttemptHTTP2:     true,
 		MaxIdleConns:          100,
@@ -60,3 +104,97 @@ func httpTransport(host string) *http.Transport {
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 }
+
+// fdTransport runs HTTP over the one connection inherited as a file
+// descriptor. Requests fail once that connection has closed, since the
+// descriptor can only be dialed once.
+func (c *DialConfig) fdTransport(url *url.URL) *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return c.dial(ctx, url)
+		},
+		MaxConnsPerHost: 1,
+	}
+}
+
+// withQuery returns a copy of the config with the address query applied
+func (c *DialConfig) withQuery(query url.Values) (*DialConfig, error) {
+	config := *c
+	options, err := c.SocketOptions.withQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	config.SocketOptions = *options
+	return &config, nil
+}
+
+// dialer creates a net.Dialer from the config
+func (c *DialConfig) dialer() *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout:       c.Timeout,
+		LocalAddr:     c.LocalAddr,
+		FallbackDelay: c.FallbackDelay,
+		Resolver:      c.Resolver,
+		Control:       c.control,
+	}
+	if dialer.Timeout == 0 {
+		dialer.Timeout = defaultDialTimeout
+	}
+	dialer.KeepAlive, dialer.KeepAliveConfig = c.keepAlive(defaultDialTimeout, net.KeepAliveConfig{})
+	return dialer
+}
+
+// control sets the socket options before the socket connects
+func (c *DialConfig) control(network, address string, raw syscall.RawConn) error {
+	if err := c.SocketOptions.control(network, raw, false); err != nil {
+		return err
+	}
+	if c.Control != nil {
+		return c.Control(network, address, raw)
+	}
+	return nil
+}
+
+// connect to the parsed url without any extras
+func (c *DialConfig) connect(ctx context.Context, url *url.URL) (net.Conn, error) {
+	switch {
+	case url.Scheme == "fd":
+		return dialFd(url)
+	case url.Scheme == "mem":
+		return dialMem(ctx, url.Host)
+	// Empty host means the path is a unix domain socket
+	case url.Host == "" || url.Scheme == "unix":
+		return c.dialer().DialContext(ctx, "unix", unixPath(url))
+	default:
+		return c.dialer().DialContext(ctx, "tcp", url.Host)
+	}
+}
+
+// dial connects to the parsed url
+func (c *DialConfig) dial(ctx context.Context, url *url.URL) (net.Conn, error) {
+	query := url.Query()
+	version, err := proxyVersion(query)
+	if err != nil {
+		return nil, err
+	}
+	config, err := c.withQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := config.connect(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.setConn(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// Announce ourselves to a listener expecting PROXY protocol headers
+	if version > 0 {
+		if err := WriteProxyHeader(conn, version, conn.LocalAddr(), conn.RemoteAddr()); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
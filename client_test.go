@@ -0,0 +1,112 @@
+package socket_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+)
+
+func TestDialConfigLocalAddr(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen("127.0.0.1:0")
+	is.NoErr(err)
+	defer ln.Close()
+	// Find a free source port
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	port := free.Addr().(*net.TCPAddr).Port
+	is.NoErr(free.Close())
+	config := &socket.DialConfig{
+		LocalAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: port},
+	}
+	conn, err := config.Dial(context.Background(), ln.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	is.Equal(conn.LocalAddr().(*net.TCPAddr).Port, port)
+	accepted, err := ln.Accept()
+	is.NoErr(err)
+	defer accepted.Close()
+	is.Equal(accepted.RemoteAddr().(*net.TCPAddr).Port, port)
+}
+
+func TestDialConfigResolver(t *testing.T) {
+	is := is.New(t)
+	var lookups atomic.Int32
+	config := &socket.DialConfig{
+		Timeout: time.Second,
+		Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				lookups.Add(1)
+				return nil, errors.New("no dns")
+			},
+		},
+	}
+	_, err := config.Dial(context.Background(), "myservice")
+	is.True(err != nil) // expected the lookup to fail
+	is.True(lookups.Load() > 0)
+}
+
+func TestDialConfigControl(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen("127.0.0.1:0")
+	is.NoErr(err)
+	defer ln.Close()
+	var calls atomic.Int32
+	config := &socket.DialConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			calls.Add(1)
+			return nil
+		},
+	}
+	conn, err := config.Dial(context.Background(), ln.Addr().String())
+	is.NoErr(err)
+	conn.Close()
+	is.Equal(calls.Load(), int32(1))
+	// Control errors fail the dial
+	config.Control = func(network, address string, c syscall.RawConn) error {
+		return errors.New("denied")
+	}
+	_, err = config.Dial(context.Background(), ln.Addr().String())
+	is.True(err != nil)
+}
+
+func TestDialConfigTransport(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(filepath.Join(t.TempDir(), "test.sock"))
+	is.NoErr(err)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}),
+	}
+	go server.Serve(ln)
+	defer server.Close()
+	var calls atomic.Int32
+	config := &socket.DialConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			calls.Add(1)
+			return nil
+		},
+	}
+	transport, err := config.Transport(ln.Addr().String())
+	is.NoErr(err)
+	client := &http.Client{Transport: transport, Timeout: time.Second}
+	res, err := client.Get("http://localhost")
+	is.NoErr(err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	is.NoErr(err)
+	is.Equal(string(body), "ok")
+	is.Equal(calls.Load(), int32(1))
+}
@@ -0,0 +1,179 @@
+package socket
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ConnHandler handles a single connection. The context is canceled when the
+// server begins shutting down. The connection is closed after the handler
+// returns.
+type ConnHandler func(ctx context.Context, conn net.Conn)
+
+// ServeConn calls the handler for each connection accepted by the listener.
+// When the context is canceled, the server stops accepting connections,
+// cancels the handler contexts and waits for the handlers to return. Any
+// connections still open after a forced shutdown are closed.
+func ServeConn(ctx context.Context, listener net.Listener, handler ConnHandler, options ...Option) error {
+	return ServeServer(ctx, listener, newConnServer(handler, newConfig(options)), options...)
+}
+
+// connServer tracks active connections for a graceful shutdown
+type connServer struct {
+	handler ConnHandler
+	config  *config
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	listener net.Listener
+	metrics  *listenerMetrics
+	closing  bool
+	conns    map[net.Conn]func() // closed connections report to the metrics
+	wg       sync.WaitGroup
+}
+
+func newConnServer(handler ConnHandler, config *config) *connServer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &connServer{
+		handler: handler,
+		config:  config,
+		ctx:     ctx,
+		cancel:  cancel,
+		conns:   map[net.Conn]func(){},
+	}
+}
+
+// Serve accepts connections until the listener is closed
+func (s *connServer) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		listener.Close()
+		return net.ErrClosed
+	}
+	s.listener = listener
+	if s.config.metrics != nil {
+		s.metrics = s.config.metrics.listener(Format(listener))
+	}
+	s.mu.Unlock()
+	for {
+		conn, err := acceptBackoff(listener)
+		if err != nil {
+			if s.isClosing() {
+				return net.ErrClosed
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			continue
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *connServer) handle(conn net.Conn) {
+	defer s.untrack(conn)
+	defer conn.Close()
+	s.handler(s.ctx, conn)
+}
+
+func (s *connServer) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.conns[conn] = func() {}
+	if s.metrics != nil {
+		s.conns[conn] = s.metrics.open()
+	}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *connServer) untrack(conn net.Conn) {
+	s.mu.Lock()
+	closed := s.conns[conn]
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	closed()
+	s.wg.Done()
+}
+
+func (s *connServer) isClosing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+// Shutdown stops accepting connections and cancels the handler contexts, then
+// waits for the handlers to return. If ctx is canceled first, the remaining
+// connections are closed.
+func (s *connServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	var err error
+	if s.listener != nil {
+		if err = s.listener.Close(); errors.Is(err, net.ErrClosed) {
+			err = nil
+		}
+	}
+	s.mu.Unlock()
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		s.mu.Lock()
+		s.config.log().Warn("closing connections that are still open", "conns", len(s.conns))
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// acceptBackoff accepts the next connection. Like http.Server, it backs off
+// on temporary errors like running out of file descriptors.
+func acceptBackoff(ln net.Listener) (net.Conn, error) {
+	var delay time.Duration
+	for {
+		conn, err := ln.Accept()
+		if err != nil && isTemporary(err) {
+			delay = backoff(delay)
+			time.Sleep(delay)
+			continue
+		}
+		return conn, err
+	}
+}
+
+// isTemporary is true for accept errors worth retrying, like timeouts or
+// running out of file descriptors
+func isTemporary(err error) bool {
+	if errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// backoff doubles the delay between 5ms and 1s
+func backoff(delay time.Duration) time.Duration {
+	if delay == 0 {
+		return 5 * time.Millisecond
+	}
+	return min(2*delay, time.Second)
+}
@@ -0,0 +1,92 @@
+package socket_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestServeConn(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener, err := socket.Listen(":0")
+	is.NoErr(err)
+	handler := func(ctx context.Context, conn net.Conn) {
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		conn.Write([]byte(line))
+	}
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.ServeConn(ctx, listener, handler) })
+	conn, err := socket.Dial(ctx, listener.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello\n"))
+	is.NoErr(err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	is.NoErr(err)
+	is.Equal(line, "hello\n")
+	cancel()
+	is.NoErr(eg.Wait())
+	_, err = socket.Dial(context.Background(), listener.Addr().String())
+	is.True(err != nil) // should have stopped
+}
+
+func TestServeConnShutdownContext(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener, err := socket.Listen(":0")
+	is.NoErr(err)
+	accepted := make(chan struct{})
+	handler := func(ctx context.Context, conn net.Conn) {
+		close(accepted)
+		<-ctx.Done()
+		conn.Write([]byte("bye\n"))
+	}
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.ServeConn(ctx, listener, handler) })
+	conn, err := socket.Dial(ctx, listener.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	<-accepted
+	cancel()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	is.NoErr(err)
+	is.Equal(line, "bye\n")
+	is.NoErr(eg.Wait())
+}
+
+func TestServeConnGracePeriod(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener, err := socket.Listen(":0")
+	is.NoErr(err)
+	accepted := make(chan struct{})
+	handler := func(ctx context.Context, conn net.Conn) {
+		close(accepted)
+		// Ignore the context and block on reads
+		io.Copy(io.Discard, conn)
+	}
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.ServeConn(ctx, listener, handler, socket.WithGracePeriod(50*time.Millisecond))
+	})
+	conn, err := socket.Dial(ctx, listener.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	<-accepted
+	cancel()
+	is.NoErr(eg.Wait())
+	// The connection should have been force-closed
+	_, err = conn.Read(make([]byte, 1))
+	is.Equal(err, io.EOF)
+}
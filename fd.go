@@ -3,11 +3,14 @@
 package socket
 
 import (
+	"fmt"
 	"net"
 	"net/url"
 	"os"
 	"strconv"
+	"sync"
 	"syscall"
+	"time"
 )
 
 func listenFd(url *url.URL) (net.Listener, error) {
@@ -16,11 +19,93 @@ func listenFd(url *url.URL) (net.Listener, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Launchers like systemd with Accept=yes pass a connected socket instead,
+	// which is served as the only connection
+	if isConnected(fd) {
+		conn, err := dialFd(url)
+		if err != nil {
+			return nil, err
+		}
+		return newSingleListener(conn, fdURL(url.Host)), nil
+	}
 	syscall.CloseOnExec(fd)
 	file := os.NewFile(uintptr(fd), url.Host)
 	ln, err := net.FileListener(file)
 	if err != nil {
 		return nil, err
 	}
-	return ln, nil
+	return &fdListener{Listener: ln, fd: url.Host}, nil
+}
+
+// fdListener remembers the file descriptor it came from, so it formats as
+// fd:N rather than the address of the socket. It forwards the methods shared
+// by *net.TCPListener and *net.UnixListener.
+type fdListener struct {
+	net.Listener
+	fd string
+}
+
+func (l *fdListener) origin() *url.URL {
+	return fdURL(l.fd)
+}
+
+// File returns a copy of the underlying file
+func (l *fdListener) File() (*os.File, error) {
+	f, ok := l.Listener.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, fmt.Errorf("socket: unable to access the file of %T", l.Listener)
+	}
+	return f.File()
+}
+
+// SetDeadline sets the deadline for Accept
+func (l *fdListener) SetDeadline(t time.Time) error {
+	d, ok := l.Listener.(interface{ SetDeadline(time.Time) error })
+	if !ok {
+		return fmt.Errorf("socket: unable to set the deadline of %T", l.Listener)
+	}
+	return d.SetDeadline(t)
+}
+
+// SyscallConn lets the backlog be set on the socket
+func (l *fdListener) SyscallConn() (syscall.RawConn, error) {
+	sc, ok := l.Listener.(syscall.Conn)
+	if !ok {
+		return nil, fmt.Errorf("socket: unable to access the socket of %T", l.Listener)
+	}
+	return sc.SyscallConn()
+}
+
+func (l *fdListener) Unwrap() net.Listener {
+	return l.Listener
+}
+
+// fdURL is the address of a file descriptor
+func fdURL(fd string) *url.URL {
+	return &url.URL{Scheme: "fd", Host: fd}
+}
+
+// dialedFds are the descriptors that were already dialed. Dialing closes the
+// descriptor, so its number may since belong to an unrelated file.
+var dialedFds sync.Map // int -> struct{}
+
+func dialFd(url *url.URL) (net.Conn, error) {
+	// Connected sockets are inherited from a launcher like inetd or socketpair
+	fd, err := strconv.Atoi(url.Host)
+	if err != nil {
+		return nil, err
+	}
+	if _, dialed := dialedFds.LoadOrStore(fd, struct{}{}); dialed {
+		return nil, fmt.Errorf("socket: the connection on fd:%s was already used", url.Host)
+	}
+	syscall.CloseOnExec(fd)
+	file := os.NewFile(uintptr(fd), url.Host)
+	// FileConn duplicates the descriptor, so close the original to let closing
+	// the connection close the socket
+	defer file.Close()
+	conn, err := net.FileConn(file)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
 }
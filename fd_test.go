@@ -0,0 +1,194 @@
+//go:build unix
+
+package socket_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+)
+
+// dialedFds are the descriptors handed out by socketpair. Each can only be
+// dialed once per process.
+var dialedFds = map[int]bool{}
+
+// socketpair returns a connection for one end and the fd of the other, which
+// is owned by whatever dials it
+func socketpair(t testing.TB) (net.Conn, string) {
+	t.Helper()
+	is := is.New(t)
+	var fds [2]int
+	for {
+		pair, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+		is.NoErr(err)
+		if !dialedFds[pair[1]] {
+			dialedFds[pair[1]] = true
+			fds = pair
+			break
+		}
+		// Hold onto reused numbers until there's a fresh one
+		defer syscall.Close(pair[0])
+		defer syscall.Close(pair[1])
+	}
+	file := os.NewFile(uintptr(fds[0]), "server")
+	conn, err := net.FileConn(file)
+	is.NoErr(err)
+	is.NoErr(file.Close())
+	t.Cleanup(func() { conn.Close() })
+	return conn, "fd:" + strconv.Itoa(fds[1])
+}
+
+func TestDialFd(t *testing.T) {
+	is := is.New(t)
+	server, addr := socketpair(t)
+	conn, err := socket.Dial(context.Background(), addr)
+	is.NoErr(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("ping"))
+	is.NoErr(err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(server, buf)
+	is.NoErr(err)
+	is.Equal(string(buf), "ping")
+}
+
+func TestDialFdClose(t *testing.T) {
+	is := is.New(t)
+	server, addr := socketpair(t)
+	conn, err := socket.Dial(context.Background(), addr)
+	is.NoErr(err)
+	is.NoErr(conn.Close())
+	// Closing the connection closes the socket, so the peer reads EOF
+	is.NoErr(server.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = server.Read(make([]byte, 1))
+	is.Equal(err, io.EOF)
+}
+
+func TestDialFdTwice(t *testing.T) {
+	is := is.New(t)
+	_, addr := socketpair(t)
+	conn, err := socket.Dial(context.Background(), addr)
+	is.NoErr(err)
+	is.NoErr(conn.Close())
+	// The number may belong to another file by now
+	file, err := os.Open(os.DevNull)
+	is.NoErr(err)
+	defer file.Close()
+	_, err = socket.Dial(context.Background(), addr)
+	is.Equal(err.Error(), "socket: the connection on "+addr+" was already used")
+	// The other file stays open
+	_, err = file.Stat()
+	is.NoErr(err)
+}
+
+func TestListenFdConnected(t *testing.T) {
+	is := is.New(t)
+	peer, addr := socketpair(t)
+	ln, err := socket.Listen(addr)
+	is.NoErr(err)
+	defer ln.Close()
+	is.Equal(socket.Format(ln), addr)
+	// The connected socket is the only connection
+	conn, err := ln.Accept()
+	is.NoErr(err)
+	_, err = peer.Write([]byte("ping"))
+	is.NoErr(err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	is.NoErr(err)
+	is.Equal(string(buf), "ping")
+	is.NoErr(conn.Close())
+	_, err = ln.Accept()
+	is.True(err != nil)
+	// Closing the connection closes the socket
+	is.NoErr(peer.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = peer.Read(make([]byte, 1))
+	is.Equal(err, io.EOF)
+}
+
+func TestTransportFd(t *testing.T) {
+	is := is.New(t)
+	server, addr := socketpair(t)
+	// Answer requests on the single connection
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		reader := bufio.NewReader(server)
+		for {
+			req, err := http.ReadRequest(reader)
+			if err != nil {
+				return
+			}
+			io.WriteString(server, "HTTP/1.1 200 OK\r\nContent-Length: "+strconv.Itoa(len(req.URL.Path))+"\r\n\r\n"+req.URL.Path)
+		}
+	}()
+	transport, err := socket.Transport(addr)
+	is.NoErr(err)
+	client := &http.Client{Transport: transport, Timeout: time.Second}
+	for _, path := range []string{"/a", "/b"} {
+		res, err := client.Get("http://localhost" + path)
+		is.NoErr(err)
+		body, err := io.ReadAll(res.Body)
+		is.NoErr(err)
+		res.Body.Close()
+		is.Equal(string(body), path)
+	}
+	// Closing the idle connection closes the socket
+	transport.CloseIdleConnections()
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		is.Fail() // expected the server to read EOF
+	}
+	// Once the connection is gone, there's nothing to reconnect to
+	_, err = client.Get("http://localhost/c")
+	is.True(err != nil)
+}
+
+func TestFormatFd(t *testing.T) {
+	is := is.New(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	defer ln.Close()
+	file, err := ln.(*net.TCPListener).File()
+	is.NoErr(err)
+	defer file.Close()
+	// Hand the listener its own copy, since it takes ownership
+	fd, err := syscall.Dup(int(file.Fd()))
+	is.NoErr(err)
+	addr := "fd:" + strconv.Itoa(fd)
+	fdListener, err := socket.Listen(addr)
+	is.NoErr(err)
+	defer fdListener.Close()
+	// The methods of *net.TCPListener are forwarded
+	tcpListener, ok := fdListener.(interface {
+		File() (*os.File, error)
+		SetDeadline(time.Time) error
+		syscall.Conn
+	})
+	is.True(ok)
+	dup, err := tcpListener.File()
+	is.NoErr(err)
+	is.NoErr(dup.Close())
+	is.NoErr(tcpListener.SetDeadline(time.Now().Add(-time.Second)))
+	_, err = fdListener.Accept()
+	is.True(errors.Is(err, os.ErrDeadlineExceeded))
+	_, err = tcpListener.SyscallConn()
+	is.NoErr(err)
+	// The listener keeps its origin rather than the address of the socket
+	is.Equal(socket.Format(fdListener), addr)
+	u, err := socket.Parse(socket.Format(fdListener))
+	is.NoErr(err)
+	is.Equal(u.String(), socket.FormatURL(fdListener).String())
+}
@@ -11,3 +11,7 @@ import (
 func listenFd(*url.URL) (net.Listener, error) {
 	return nil, fmt.Errorf("socket: listening on a file descriptor is not supported on windows")
 }
+
+func dialFd(*url.URL) (net.Conn, error) {
+	return nil, fmt.Errorf("socket: dialing a file descriptor is not supported on windows")
+}
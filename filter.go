@@ -0,0 +1,91 @@
+package socket
+
+import (
+	"net"
+	"net/url"
+)
+
+// FilterListener closes connections from peers outside the allowed networks or
+// inside the denied networks before they're returned from Accept. Wrap a
+// ProxyListener to filter on the real client address, but only one with
+// Trusted networks, since any peer can send a PROXY header otherwise. Peers
+// without an IP address, like unix domain sockets, are always accepted.
+type FilterListener struct {
+	net.Listener
+
+	// Allow only peers within these networks. An empty list allows every peer.
+	Allow []*net.IPNet
+
+	// Deny peers within these networks, even if they're allowed
+	Deny []*net.IPNet
+
+	rejections
+}
+
+var _ net.Listener = (*FilterListener)(nil)
+
+// Accept waits for the next connection from an allowed peer
+func (l *FilterListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.allows(conn.RemoteAddr()) {
+			return conn, nil
+		}
+		conn.Close()
+		l.reject("denied")
+	}
+}
+
+func (l *FilterListener) allows(addr net.Addr) bool {
+	ip := addrIP(addr)
+	if ip == nil {
+		return true
+	}
+	for _, network := range l.Deny {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	if len(l.Allow) == 0 {
+		return true
+	}
+	for _, network := range l.Allow {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the underlying listener
+func (l *FilterListener) Unwrap() net.Listener {
+	return l.Listener
+}
+
+// filterListener configures a FilterListener from the address query
+// (e.g. ?allow=10.0.0.0/8,127.0.0.1&deny=10.0.0.1)
+func filterListener(query url.Values) (*FilterListener, error) {
+	allow, deny := query.Get("allow"), query.Get("deny")
+	if allow == "" && deny == "" {
+		return nil, nil
+	}
+	fl := new(FilterListener)
+	if allow != "" {
+		networks, err := parseCIDRs(allow)
+		if err != nil {
+			return nil, err
+		}
+		fl.Allow = networks
+	}
+	if deny != "" {
+		networks, err := parseCIDRs(deny)
+		if err != nil {
+			return nil, err
+		}
+		fl.Deny = networks
+	}
+	return fl, nil
+}
@@ -0,0 +1,98 @@
+package socket_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+)
+
+// proxyGet sends a PROXY header from src, then requests / over HTTP/1.0
+func proxyGet(t testing.TB, ln net.Listener, src string) (string, error) {
+	t.Helper()
+	is := is.New(t)
+	conn, err := socket.Dial(context.Background(), ln.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	srcAddr, err := net.ResolveTCPAddr("tcp", src)
+	is.NoErr(err)
+	is.NoErr(socket.WriteProxyHeader(conn, 1, srcAddr, ln.Addr()))
+	if _, err := io.WriteString(conn, "GET / HTTP/1.0\r\n\r\n"); err != nil {
+		return "", err
+	}
+	res, err := io.ReadAll(conn)
+	return string(res), err
+}
+
+func TestFilterAllow(t *testing.T) {
+	is := is.New(t)
+	ln := serveRemoteAddr(t, ":0?allow=10.0.0.0/8,127.0.0.1")
+	is.True(strings.HasPrefix(get(t, ln.Addr().String()), "127.0.0.1:"))
+}
+
+func TestFilterDeny(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(":0?deny=127.0.0.0/8")
+	is.NoErr(err)
+	label := socket.Format(ln)
+	metrics := new(socket.Metrics)
+	opened := serveHold(t, ln, socket.WithMetrics(metrics))
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(make([]byte, 1))
+	is.True(err != nil)
+	is.Equal(n, 0)
+	select {
+	case <-opened:
+		t.Fatal("expected the connection to be rejected")
+	default:
+	}
+	var vars map[string]map[string]any
+	for start := time.Now(); time.Since(start) < 500*time.Millisecond; time.Sleep(5 * time.Millisecond) {
+		is.NoErr(json.Unmarshal([]byte(metrics.Var().String()), &vars))
+		if rejected, _ := vars[label]["connections_rejected"].(map[string]any); rejected["denied"] == 1.0 {
+			return
+		}
+	}
+	t.Fatalf("expected a rejection, got %v", vars[label]["connections_rejected"])
+}
+
+func TestFilterProxy(t *testing.T) {
+	is := is.New(t)
+	ln := serveRemoteAddr(t, ":0?proxy=v1&proxy_trusted=127.0.0.1&allow=203.0.113.0/24&deny=203.0.113.99")
+	res, err := proxyGet(t, ln, "203.0.113.7:4321")
+	is.NoErr(err)
+	is.True(strings.HasSuffix(res, "203.0.113.7:4321"))
+	// Filtered on the address from the header, not the load balancer's
+	res, _ = proxyGet(t, ln, "198.51.100.1:4321")
+	is.Equal(res, "")
+	res, _ = proxyGet(t, ln, "203.0.113.99:4321")
+	is.Equal(res, "")
+}
+
+func TestFilterProxyUntrusted(t *testing.T) {
+	is := is.New(t)
+	// Any peer could claim an allowed address without trusted load balancers
+	_, err := socket.Listen(":0?proxy=v1&allow=203.0.113.0/24")
+	is.True(err != nil)
+	is.Equal(err.Error(), "socket: allow and deny need proxy_trusted when combined with proxy")
+	_, err = socket.Listen(":0?proxy=v2&deny=203.0.113.99")
+	is.True(err != nil)
+}
+
+func TestFilterInvalid(t *testing.T) {
+	is := is.New(t)
+	_, err := socket.Listen(":0?allow=10.0.0.0/33")
+	is.True(err != nil) // expected an error
+	_, err = socket.Listen(":0?deny=nope")
+	is.True(err != nil) // expected an error
+}
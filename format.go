@@ -1,25 +1,105 @@
 package socket
 
 import (
-	"fmt"
+	"crypto/tls"
 	"net"
+	"net/url"
+	"reflect"
+	"strings"
 )
 
-// Format a listener
+// Format a listener. Unix domain sockets format as their path and file
+// descriptors as fd:N, otherwise the output is a URL. The output parses back
+// to the same endpoint.
 func Format(l net.Listener) string {
-	address := l.Addr().String()
-	if l.Addr().Network() == "unix" {
-		return address
+	return formatURL(FormatURL(l))
+}
+
+// FormatURL returns the URL of a listener, looking through wrapped listeners
+// for its origin and TLS
+func FormatURL(l net.Listener) *url.URL {
+	u := formatAddr(l.Addr())
+	for ln := l; ln != nil; {
+		if o, ok := ln.(origin); ok {
+			return o.origin()
+		}
+		if reflect.TypeOf(ln) == tlsListenerType && u.Scheme == "http" {
+			u.Scheme = "https"
+		}
+		unwrapper, ok := ln.(interface{ Unwrap() net.Listener })
+		if !ok {
+			break
+		}
+		ln = unwrapper.Unwrap()
+	}
+	return u
+}
+
+// FormatAddr formats an address like Format
+func FormatAddr(addr net.Addr) string {
+	return formatURL(formatAddr(addr))
+}
+
+// FormatConn formats the remote address of a connection like Format
+func FormatConn(conn net.Conn) string {
+	u := formatAddr(conn.RemoteAddr())
+	for c := conn; u.Scheme == "http"; {
+		if _, ok := c.(*tls.Conn); ok {
+			u.Scheme = "https"
+		}
+		unwrapper, ok := c.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		c = unwrapper.NetConn()
+	}
+	return formatURL(u)
+}
+
+// origin is implemented by listeners whose address doesn't show where they
+// came from, like file descriptors
+type origin interface {
+	origin() *url.URL
+}
+
+// tlsListenerType is the type of listeners from tls.NewListener
+var tlsListenerType = reflect.TypeOf(tls.NewListener(nil, nil))
+
+func formatAddr(addr net.Addr) *url.URL {
+	address := addr.String()
+	switch addr.Network() {
+	case "unix", "unixpacket":
+		// Relative paths like ./some/path parse with "." as the host
+		if strings.HasPrefix(address, "./") {
+			return &url.URL{Scheme: "unix", Host: ".", Path: address[1:]}
+		}
+		return &url.URL{Scheme: "unix", Path: address}
+	case "mem":
+		return &url.URL{Scheme: "mem", Host: strings.TrimPrefix(address, "mem:")}
+	case "stdio":
+		return &url.URL{Scheme: "stdio"}
 	}
 	host, port, err := net.SplitHostPort(address)
 	if err != nil {
-		// Give up trying to format.
-		// TODO: figure out if this can occur.
-		return address
+		// Give up trying to format
+		return &url.URL{Scheme: addr.Network(), Opaque: address}
+	}
+	// Bracket IPv6 hosts, so the output parses back to the same endpoint
+	return &url.URL{Scheme: "http", Host: net.JoinHostPort(host, port)}
+}
+
+// formatURL returns the short form of the url
+func formatURL(u *url.URL) string {
+	switch u.Scheme {
+	case "unix":
+		return unixPath(u)
+	case "fd", "mem":
+		return u.Scheme + ":" + u.Host
+	case "stdio":
+		return "stdio:"
 	}
-	// https://serverfault.com/a/444557
-	if host == "::" {
-		host = "0.0.0.0"
+	if u.Opaque != "" {
+		return u.Opaque
 	}
-	return fmt.Sprintf("http://%s:%s", host, port)
+	return u.String()
 }
@@ -1,10 +1,16 @@
 package socket_test
 
 import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/netip"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/matryer/is"
 	"github.com/matthewmueller/socket"
 )
 
@@ -44,7 +50,9 @@ func TestFormat(t *testing.T) {
 	formatEq(t, "5000", "http://127.0.0.1:5000")
 	formatEq(t, ":5000", "http://127.0.0.1:5000")
 
-	formatEq(t, "0.0.0.0:4000", "http://0.0.0.0:4000")
+	// 0.0.0.0 listens on both IPv4 and IPv6
+	formatEq(t, "0.0.0.0:4000", "http://[::]:4000")
+	formatContains(t, "[::]:0", "http://[::]:")
 
 	// Random
 	formatContains(t, "", "http://127.0.0.1:")
@@ -54,4 +62,129 @@ func TestFormat(t *testing.T) {
 	// Socket
 	socketPath := filepath.Join(t.TempDir(), "/test.sock")
 	formatEq(t, socketPath, socketPath)
+
+	// Other schemes
+	formatEq(t, "mem:format", "mem:format")
+}
+
+func TestFormatIPv6(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen("http://[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 isn't available: %v", err)
+	}
+	defer ln.Close()
+	formatted := socket.Format(ln)
+	is.True(strings.HasPrefix(formatted, "http://[::1]:"))
+	u, err := socket.Parse(formatted)
+	is.NoErr(err)
+	sameEndpoint(t, ln, u)
+}
+
+func TestFormatTLS(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	defer ln.Close()
+	tlsListener := tls.NewListener(ln, &tls.Config{})
+	is.True(strings.HasPrefix(socket.Format(tlsListener), "https://127.0.0.1:"))
+	// Including through the wrappers in this package
+	limited := &socket.LimitListener{Listener: tlsListener, Max: 1}
+	is.True(strings.HasPrefix(socket.Format(limited), "https://127.0.0.1:"))
+}
+
+func TestFormatURL(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	defer ln.Close()
+	u := socket.FormatURL(ln)
+	is.Equal(u.Scheme, "http")
+	is.Equal(u.Hostname(), "127.0.0.1")
+	is.Equal(u.String(), socket.Format(ln))
+	sameEndpoint(t, ln, u)
+
+	// Unix domain sockets format as their path, but their URL has a scheme
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+	ln, err = socket.Listen(socketPath)
+	is.NoErr(err)
+	defer ln.Close()
+	u = socket.FormatURL(ln)
+	is.Equal(u.String(), "unix://"+socketPath)
+	sameEndpoint(t, ln, u)
+}
+
+func TestFormatAddr(t *testing.T) {
+	is := is.New(t)
+	is.Equal(socket.FormatAddr(&net.TCPAddr{IP: net.ParseIP("::1"), Port: 80}), "http://[::1]:80")
+	is.Equal(socket.FormatAddr(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 80}), "http://10.0.0.1:80")
+	is.Equal(socket.FormatAddr(&net.UnixAddr{Name: "/tmp/test.sock", Net: "unix"}), "/tmp/test.sock")
+	is.Equal(socket.FormatAddr(&net.UnixAddr{Name: "./test.sock", Net: "unix"}), "./test.sock")
+}
+
+func TestFormatConn(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	defer ln.Close()
+	conn, err := socket.Dial(context.Background(), socket.Format(ln))
+	is.NoErr(err)
+	defer conn.Close()
+	// Connections format as their remote address
+	is.Equal(socket.FormatConn(conn), socket.Format(ln))
+	is.Equal(socket.FormatConn(tls.Client(conn, &tls.Config{})), "https"+strings.TrimPrefix(socket.Format(ln), "http"))
+}
+
+// sameEndpoint checks that the url targets the listener
+func sameEndpoint(t testing.TB, ln net.Listener, u *url.URL) {
+	t.Helper()
+	switch addr := ln.Addr().(type) {
+	case *net.TCPAddr:
+		target, err := netip.ParseAddrPort(u.Host)
+		if err != nil {
+			t.Fatalf("%q doesn't target an IP and port: %v", u, err)
+		}
+		if target.Addr() != addr.AddrPort().Addr().Unmap() || target.Port() != addr.AddrPort().Port() {
+			t.Fatalf("%q doesn't target %s", u, addr)
+		}
+	case *net.UnixAddr:
+		if u.Scheme != "unix" || u.Host+u.Path != addr.Name {
+			t.Fatalf("%q doesn't target %s", u, addr)
+		}
+	default:
+		if u.Scheme != addr.Network() || u.Scheme+":"+u.Host != addr.String() {
+			t.Fatalf("%q doesn't target %s", u, addr)
+		}
+	}
+}
+
+func FuzzFormat(f *testing.F) {
+	for _, seed := range []string{"0", ":0", "127.0.0.1:0", "0.0.0.0:0", "[::]:0", "mem:fuzz", "/fuzz.sock", ":0?proxy=v2"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		u, err := socket.Parse(input)
+		if err != nil {
+			return
+		}
+		switch u.Scheme {
+		case "fd", "stdio":
+			// Listening would take over the test's own files
+			return
+		case "unix":
+			// Keep socket files out of the working directory
+			input = filepath.Join(t.TempDir(), "fuzz.sock")
+		}
+		ln, err := socket.Listen(input)
+		if err != nil {
+			return
+		}
+		defer ln.Close()
+		formatted := socket.Format(ln)
+		u, err = socket.Parse(formatted)
+		if err != nil {
+			t.Fatalf("%q formatted as %q, which doesn't parse: %v", input, formatted, err)
+		}
+		sameEndpoint(t, ln, u)
+	})
 }
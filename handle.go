@@ -0,0 +1,116 @@
+package socket
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+)
+
+// Handle to a running server whose handler can be swapped without restarting
+// the listener
+type Handle struct {
+	handler atomic.Pointer[http.Handler]
+	reload  func(ctx context.Context) (http.Handler, error)
+	log     *slog.Logger
+	done    chan struct{}
+	err     error
+}
+
+// Start serving the handler in the background. When the context is canceled,
+// the server will be gracefully shutdown. Call Wait for the result.
+func Start(ctx context.Context, listener net.Listener, handler http.Handler, options ...Option) *Handle {
+	config := newConfig(options)
+	h := start(ctx, listener, handler, config, options)
+	if config.reload != nil {
+		reloadOnHangup(ctx, config, h.done, h)
+	}
+	return h
+}
+
+// start serving the handler in the background without reloading
+func start(ctx context.Context, listener net.Listener, handler http.Handler, config *config, options []Option) *Handle {
+	h := &Handle{
+		reload: config.reload,
+		log:    config.log(),
+		done:   make(chan struct{}),
+	}
+	h.SetHandler(handler)
+	// Create the HTTP server
+	server := newHTTPServer(&http.Server{
+		Addr:    listener.Addr().String(),
+		Handler: http.HandlerFunc(h.serveHTTP),
+	}, config)
+	go func() {
+		defer close(h.done)
+		h.err = ServeServer(ctx, listener, server, options...)
+	}()
+	return h
+}
+
+// SetHandler atomically swaps the handler. In-flight requests finish on the
+// old handler and new requests go to the new one.
+func (h *Handle) SetHandler(handler http.Handler) {
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	h.handler.Store(&handler)
+}
+
+// Reload calls the reload function passed to WithReload and swaps in the
+// handler it returns. The current handler is kept if reloading fails.
+func (h *Handle) Reload(ctx context.Context) error {
+	if h.reload == nil {
+		return nil
+	}
+	handler, err := h.reload(ctx)
+	if err != nil {
+		return err
+	}
+	h.SetHandler(handler)
+	return nil
+}
+
+// Wait for the server to shutdown
+func (h *Handle) Wait() error {
+	<-h.done
+	return h.err
+}
+
+func (h *Handle) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	(*h.handler.Load()).ServeHTTP(w, r)
+}
+
+// reloadOnHangup reloads once on every SIGHUP until the context is canceled or
+// the servers are done. Servers that share a lifecycle share the reloaded
+// handler.
+func reloadOnHangup(ctx context.Context, config *config, done <-chan struct{}, handles ...*Handle) {
+	log := config.log()
+	// Notify before returning so a SIGHUP doesn't kill the process
+	hangup := make(chan os.Signal, 1)
+	notifyHangup(hangup)
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-hangup:
+				handler, err := config.reload(ctx)
+				if err != nil {
+					log.Error("reload failed", "err", err)
+					continue
+				}
+				for _, h := range handles {
+					h.SetHandler(handler)
+				}
+				log.Info("reloaded handler")
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package socket
+
+import "os"
+
+// notifyHangup does nothing on platforms without SIGHUP. Call Handle.Reload to
+// reload instead.
+func notifyHangup(chan<- os.Signal) {}
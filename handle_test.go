@@ -0,0 +1,56 @@
+package socket_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+)
+
+func text(s string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(s))
+	})
+}
+
+func getBody(t testing.TB, url string) string {
+	t.Helper()
+	is := is.New(t)
+	res, err := http.Get(url)
+	is.NoErr(err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	is.NoErr(err)
+	return string(body)
+}
+
+func TestStartSetHandler(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	url := "http://" + ln.Addr().String()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("old"))
+	})
+	handle := socket.Start(ctx, ln, slow)
+	// Start a request on the old handler
+	inflight := make(chan string, 1)
+	go func() { inflight <- getBody(t, url) }()
+	<-started
+	// Swap the handler while the request is in-flight
+	handle.SetHandler(text("new"))
+	is.Equal(getBody(t, url), "new")
+	close(release)
+	is.Equal(<-inflight, "old")
+	cancel()
+	is.NoErr(handle.Wait())
+}
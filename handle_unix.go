@@ -0,0 +1,14 @@
+//go:build unix
+
+package socket
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyHangup relays SIGHUP to the channel
+func notifyHangup(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGHUP)
+}
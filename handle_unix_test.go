@@ -0,0 +1,88 @@
+//go:build unix
+
+package socket_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestStartReloadOnHangup(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	url := "http://" + ln.Addr().String()
+	reloads := 0
+	reloaded := make(chan struct{}, 2)
+	reload := func(ctx context.Context) (http.Handler, error) {
+		defer func() { reloaded <- struct{}{} }()
+		reloads++
+		if reloads == 2 {
+			return nil, errors.New("bad config")
+		}
+		return text("reloaded"), nil
+	}
+	handle := socket.Start(ctx, ln, text("initial"), socket.WithReload(reload))
+	is.Equal(getBody(t, url), "initial")
+	process, err := os.FindProcess(os.Getpid())
+	is.NoErr(err)
+	is.NoErr(process.Signal(syscall.SIGHUP))
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		is.Fail() // should have reloaded
+	}
+	is.Equal(getBody(t, url), "reloaded")
+	// A failed reload keeps the current handler
+	is.NoErr(process.Signal(syscall.SIGHUP))
+	<-reloaded
+	is.Equal(getBody(t, url), "reloaded")
+	cancel()
+	is.NoErr(handle.Wait())
+}
+
+func TestServeAllReloadOnce(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln1, err := socket.Listen(":0")
+	is.NoErr(err)
+	ln2, err := socket.Listen(":0")
+	is.NoErr(err)
+	var reloads atomic.Int32
+	reload := func(ctx context.Context) (http.Handler, error) {
+		reloads.Add(1)
+		return text("reloaded"), nil
+	}
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.ServeAll(ctx, []net.Listener{ln1, ln2}, text("initial"), socket.WithReload(reload))
+	})
+	is.Equal(get(t, ln1.Addr().String()), "initial")
+	is.Equal(get(t, ln2.Addr().String()), "initial")
+	process, err := os.FindProcess(os.Getpid())
+	is.NoErr(err)
+	is.NoErr(process.Signal(syscall.SIGHUP))
+	// Every listener gets the handler from a single reload
+	for getBody(t, "http://"+ln2.Addr().String()) != "reloaded" {
+		time.Sleep(10 * time.Millisecond)
+	}
+	is.Equal(getBody(t, "http://"+ln1.Addr().String()), "reloaded")
+	time.Sleep(50 * time.Millisecond)
+	is.Equal(reloads.Load(), int32(1))
+	cancel()
+	is.NoErr(eg.Wait())
+}
@@ -0,0 +1,243 @@
+package socket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCheckTimeout is how long each health check can take
+const defaultCheckTimeout = 5 * time.Second
+
+// State of a server's lifecycle
+type State int32
+
+const (
+	// StateStarting is the state before the server accepts connections
+	StateStarting State = iota
+	// StateServing is the state while the server accepts connections
+	StateServing
+	// StateDraining is the state after the server starts shutting down
+	StateDraining
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStarting:
+		return "starting"
+	case StateServing:
+		return "serving"
+	case StateDraining:
+		return "draining"
+	default:
+		return "unknown"
+	}
+}
+
+// Check reports an unhealthy dependency by returning an error
+type Check func(ctx context.Context) error
+
+// Health serves /healthz, /readyz and /livez endpoints driven by registered
+// checks. Readiness also follows the lifecycle of the servers it's passed to
+// with WithHealth: it's only ready while serving.
+type Health struct {
+	// Addr to serve the endpoints on, like an admin unix socket. When empty,
+	// the endpoints are mounted on the main listener.
+	Addr string
+
+	// Timeout for each check. Defaults to 5 seconds.
+	Timeout time.Duration
+
+	mu    sync.RWMutex
+	ready []namedCheck
+	live  []namedCheck
+	state atomic.Int32
+
+	// admin serves the endpoints on Addr for every server sharing the health
+	adminMu   sync.Mutex
+	adminRefs int
+	admin     *http.Server
+}
+
+var _ http.Handler = (*Health)(nil)
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Ready registers a check that must pass for /readyz and /healthz to succeed
+func (h *Health) Ready(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready = append(h.ready, namedCheck{name, check})
+}
+
+// Live registers a check that must pass for /livez and /healthz to succeed
+func (h *Health) Live(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.live = append(h.live, namedCheck{name, check})
+}
+
+// State returns the lifecycle state
+func (h *Health) State() State {
+	return State(h.state.Load())
+}
+
+// SetState changes the lifecycle state. Serve does this automatically.
+func (h *Health) SetState(state State) {
+	h.state.Store(int32(state))
+}
+
+// serveAdmin serves the endpoints on Addr. The address is bound for the first
+// server and shared with the rest.
+func (h *Health) serveAdmin() error {
+	h.adminMu.Lock()
+	defer h.adminMu.Unlock()
+	if h.adminRefs == 0 {
+		ln, err := Listen(h.Addr)
+		if err != nil {
+			return err
+		}
+		h.admin = &http.Server{Handler: h}
+		go h.admin.Serve(ln)
+	}
+	h.adminRefs++
+	return nil
+}
+
+// releaseAdmin returns the admin server once the last server is done with it
+func (h *Health) releaseAdmin() *http.Server {
+	h.adminMu.Lock()
+	defer h.adminMu.Unlock()
+	h.adminRefs--
+	if h.adminRefs > 0 {
+		return nil
+	}
+	admin := h.admin
+	h.admin = nil
+	return admin
+}
+
+// shutdownAdmin gracefully stops serving the endpoints after the last server
+func (h *Health) shutdownAdmin(ctx context.Context) error {
+	if admin := h.releaseAdmin(); admin != nil {
+		return admin.Shutdown(ctx)
+	}
+	return nil
+}
+
+// closeAdmin stops serving the endpoints after the last server
+func (h *Health) closeAdmin() {
+	if admin := h.releaseAdmin(); admin != nil {
+		admin.Close()
+	}
+}
+
+// isEndpoint is true for the paths served by Health
+func isEndpoint(path string) bool {
+	return path == "/healthz" || path == "/readyz" || path == "/livez"
+}
+
+// ServeHTTP serves the endpoints with a JSON report. Failing endpoints respond
+// with 503 Service Unavailable.
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	var checks []namedCheck
+	withState := false
+	switch r.URL.Path {
+	case "/healthz":
+		checks = append(append(checks, h.live...), h.ready...)
+	case "/readyz":
+		checks = append(checks, h.ready...)
+		withState = true
+	case "/livez":
+		checks = append(checks, h.live...)
+	default:
+		h.mu.RUnlock()
+		http.NotFound(w, r)
+		return
+	}
+	h.mu.RUnlock()
+	report := h.run(r.Context(), checks)
+	if withState {
+		state := h.State()
+		report.State = state.String()
+		if state != StateServing {
+			report.Status = "fail"
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+type healthReport struct {
+	Status string                 `json:"status"`
+	State  string                 `json:"state,omitempty"`
+	Checks map[string]checkReport `json:"checks,omitempty"`
+}
+
+type checkReport struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// run the checks concurrently, each with its own timeout
+func (h *Health) run(ctx context.Context, checks []namedCheck) *healthReport {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+	report := &healthReport{Status: "ok"}
+	if len(checks) == 0 {
+		return report
+	}
+	reports := make([]checkReport, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i] = runCheck(ctx, timeout, check.check)
+		}()
+	}
+	wg.Wait()
+	report.Checks = make(map[string]checkReport, len(checks))
+	for i, check := range checks {
+		if reports[i].Status != "ok" {
+			report.Status = "fail"
+		}
+		report.Checks[check.name] = reports[i]
+	}
+	return report
+}
+
+// runCheck runs a single check, giving up when it times out
+func runCheck(ctx context.Context, timeout time.Duration, check Check) checkReport {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() { errCh <- check(ctx) }()
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	report := checkReport{Status: "ok", Duration: time.Since(start).String()}
+	if err != nil {
+		report.Status = "fail"
+		report.Error = err.Error()
+	}
+	return report
+}
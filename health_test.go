@@ -0,0 +1,195 @@
+package socket_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+type healthReport struct {
+	Status string `json:"status"`
+	State  string `json:"state"`
+	Checks map[string]struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"checks"`
+}
+
+func checkHealth(t testing.TB, handler http.Handler, path string) (int, *healthReport) {
+	t.Helper()
+	is := is.New(t)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	report := new(healthReport)
+	is.NoErr(json.NewDecoder(rec.Body).Decode(report))
+	return rec.Code, report
+}
+
+func TestHealthChecks(t *testing.T) {
+	is := is.New(t)
+	health := &socket.Health{Timeout: 20 * time.Millisecond}
+	health.SetState(socket.StateServing)
+	health.Live("loop", func(ctx context.Context) error { return nil })
+	health.Ready("db", func(ctx context.Context) error { return errors.New("db is down") })
+	health.Ready("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	code, report := checkHealth(t, health, "/livez")
+	is.Equal(code, 200)
+	is.Equal(report.Status, "ok")
+	is.Equal(report.Checks["loop"].Status, "ok")
+
+	code, report = checkHealth(t, health, "/readyz")
+	is.Equal(code, 503)
+	is.Equal(report.Status, "fail")
+	is.Equal(report.State, "serving")
+	is.Equal(report.Checks["db"].Error, "db is down")
+	is.Equal(report.Checks["slow"].Error, "context deadline exceeded")
+
+	code, report = checkHealth(t, health, "/healthz")
+	is.Equal(code, 503)
+	is.Equal(len(report.Checks), 3)
+}
+
+func TestHealthState(t *testing.T) {
+	is := is.New(t)
+	health := new(socket.Health)
+	code, report := checkHealth(t, health, "/readyz")
+	is.Equal(code, 503)
+	is.Equal(report.State, "starting")
+	health.SetState(socket.StateServing)
+	code, _ = checkHealth(t, health, "/readyz")
+	is.Equal(code, 200)
+	health.SetState(socket.StateDraining)
+	code, report = checkHealth(t, health, "/readyz")
+	is.Equal(code, 503)
+	is.Equal(report.State, "draining")
+	// Liveness doesn't follow the lifecycle
+	code, _ = checkHealth(t, health, "/livez")
+	is.Equal(code, 200)
+}
+
+func TestServeHealthAddr(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	admin := filepath.Join(t.TempDir(), "admin.sock")
+	health := &socket.Health{Addr: admin}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.Serve(ctx, ln, handler, socket.WithHealth(health)) })
+	// The endpoints aren't mounted on the main listener
+	res, err := http.Get("http://" + ln.Addr().String() + "/readyz")
+	is.NoErr(err)
+	body, err := io.ReadAll(res.Body)
+	is.NoErr(err)
+	res.Body.Close()
+	is.Equal(string(body), "/readyz")
+	transport, err := socket.Transport(admin)
+	is.NoErr(err)
+	client := &http.Client{Transport: transport, Timeout: time.Second}
+	res, err = client.Get("http://localhost/readyz")
+	is.NoErr(err)
+	res.Body.Close()
+	is.Equal(res.StatusCode, 200)
+	cancel()
+	is.NoErr(eg.Wait())
+	is.Equal(health.State(), socket.StateDraining)
+}
+
+func TestServeAllHealthAddr(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln1, err := socket.Listen(":0")
+	is.NoErr(err)
+	ln2, err := socket.Listen(":0")
+	is.NoErr(err)
+	admin := filepath.Join(t.TempDir(), "admin.sock")
+	health := &socket.Health{Addr: admin}
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.ServeAll(ctx, []net.Listener{ln1, ln2}, http.NotFoundHandler(), socket.WithHealth(health))
+	})
+	// The listeners share the admin address
+	transport, err := socket.Transport(admin)
+	is.NoErr(err)
+	client := &http.Client{Transport: transport, Timeout: time.Second}
+	var res *http.Response
+	for attempts := 0; ; attempts++ {
+		res, err = client.Get("http://localhost/readyz")
+		if err == nil || attempts > 5 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	is.NoErr(err)
+	res.Body.Close()
+	is.Equal(res.StatusCode, 200)
+	cancel()
+	is.NoErr(eg.Wait())
+	// The admin address is closed once every listener is done
+	transport.CloseIdleConnections()
+	_, err = client.Get("http://localhost/readyz")
+	is.True(err != nil)
+}
+
+// closeErrorListener fails to close, which isn't a forced shutdown
+type closeErrorListener struct {
+	net.Listener
+}
+
+func (l closeErrorListener) Close() error {
+	l.Listener.Close()
+	return errors.New("unable to close")
+}
+
+func TestServeHealthAddrShutdownError(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	admin := filepath.Join(t.TempDir(), "admin.sock")
+	health := &socket.Health{Addr: admin}
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.Serve(ctx, closeErrorListener{ln}, http.NotFoundHandler(), socket.WithHealth(health))
+	})
+	transport, err := socket.Transport(admin)
+	is.NoErr(err)
+	client := &http.Client{Transport: transport, Timeout: time.Second}
+	var res *http.Response
+	for attempts := 0; ; attempts++ {
+		res, err = client.Get("http://localhost/readyz")
+		if err == nil || attempts > 5 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	is.NoErr(err)
+	res.Body.Close()
+	cancel()
+	is.Equal(eg.Wait().Error(), "unable to close")
+	// The admin address is released even though the shutdown failed
+	transport.CloseIdleConnections()
+	_, err = client.Get("http://localhost/readyz")
+	is.True(err != nil)
+}
@@ -0,0 +1,351 @@
+package socket
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// shuttingDownKey stores the shutdown channel in the base context
+type shuttingDownKey struct{}
+
+// ShuttingDown returns a channel that's closed when the server that's serving
+// the request begins shutting down. Streaming handlers and owners of hijacked
+// connections should use this to send a final message and return. Contexts
+// that didn't come from Serve return a nil channel, which blocks forever.
+func ShuttingDown(ctx context.Context) <-chan struct{} {
+	ch, _ := ctx.Value(shuttingDownKey{}).(chan struct{})
+	return ch
+}
+
+// httpServer adds draining to http.Server and tracks hijacked connections,
+// which http.Server forgets about
+type httpServer struct {
+	*http.Server
+	shuttingDown chan struct{}
+	once         sync.Once
+	cancel       context.CancelFunc
+	config       *config
+
+	mu       sync.Mutex
+	hijacked map[net.Conn]struct{}
+	draining atomic.Bool
+	active   atomic.Int64
+	metrics  *listenerMetrics
+	closers  map[net.Conn]func() // report closed connections to the metrics
+
+	// health follows the lifecycle and may be served on its own address
+	health   *Health
+	adminRef atomic.Bool // holds a reference to the health's admin server
+}
+
+var _ Server = (*httpServer)(nil)
+
+func newHTTPServer(server *http.Server, config *config) *httpServer {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &httpServer{
+		Server:       server,
+		shuttingDown: make(chan struct{}),
+		cancel:       cancel,
+		config:       config,
+		hijacked:     map[net.Conn]struct{}{},
+		closers:      map[net.Conn]func(){},
+	}
+	handler := server.Handler
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	// Report readiness to load balancers while draining
+	s.health = config.health
+	if s.health == nil && config.drainDelay > 0 {
+		s.health = new(Health)
+	}
+	if s.health != nil && s.health.Addr == "" {
+		handler = mountHealth(s.health, handler)
+	}
+	log := config.log()
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w, server: s}
+		if config.accessLog {
+			defer logAccess(log, rw, r, time.Now())
+		}
+		if s.metrics != nil {
+			s.metrics.requests.Add(1)
+			s.metrics.inflight.Add(1)
+			defer s.metrics.inflight.Add(-1)
+		}
+		if config.recover {
+			defer s.recoverPanic(rw, r)
+		}
+		handler.ServeHTTP(rw, r)
+	})
+	if config.logger != nil {
+		server.ErrorLog = slog.NewLogLogger(config.logger.Handler(), slog.LevelError)
+	}
+	server.BaseContext = func(net.Listener) context.Context {
+		return context.WithValue(ctx, shuttingDownKey{}, s.shuttingDown)
+	}
+	if config.accessLog {
+		server.ConnContext = peerContext
+	}
+	server.ConnState = func(conn net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			s.active.Add(1)
+			s.opened(conn)
+		case http.StateClosed:
+			s.active.Add(-1)
+			s.closed(conn)
+		case http.StateHijacked:
+			// Catch hijacks that bypass the response writer
+			s.active.Add(-1)
+			s.track(conn)
+		}
+	}
+	return s
+}
+
+// mountHealth serves the health endpoints in front of the handler
+func mountHealth(health *Health, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isEndpoint(r.URL.Path) {
+			health.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serve the listener, along with the health endpoints if they have their own
+// address
+func (s *httpServer) Serve(listener net.Listener) error {
+	if s.config.metrics != nil {
+		s.metrics = s.config.metrics.listener(Format(listener))
+	}
+	if s.health != nil && s.health.Addr != "" {
+		if err := s.health.serveAdmin(); err != nil {
+			listener.Close()
+			return err
+		}
+		s.adminRef.Store(true)
+	}
+	if s.health != nil {
+		s.health.SetState(StateServing)
+	}
+	return s.Server.Serve(listener)
+}
+
+// Shutdown the HTTP server, then wait for the hijacked connections to close
+func (s *httpServer) Shutdown(ctx context.Context) (err error) {
+	// Keep reporting on health until the end, then release it however the
+	// shutdown went
+	defer func() {
+		if !s.adminRef.Swap(false) {
+			return
+		}
+		if err != nil {
+			s.health.closeAdmin()
+			return
+		}
+		err = s.health.shutdownAdmin(ctx)
+	}()
+	s.drain(ctx)
+	s.once.Do(s.notify)
+	if err := s.Server.Shutdown(ctx); err != nil {
+		return err
+	}
+	// Poll like http.Server.Shutdown does
+	timer := time.NewTimer(time.Millisecond)
+	defer timer.Stop()
+	for interval := time.Millisecond; s.numHijacked() > 0; {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			interval = min(2*interval, 500*time.Millisecond)
+			timer.Reset(interval)
+		}
+	}
+	return nil
+}
+
+// Close the HTTP server and the hijacked connections
+func (s *httpServer) Close() error {
+	s.once.Do(s.notify)
+	defer s.cancel()
+	s.config.log().Warn("closing connections that are still open",
+		"conns", s.active.Load(),
+		"hijacked", s.numHijacked(),
+	)
+	if s.adminRef.Swap(false) {
+		s.health.closeAdmin()
+	}
+	err := s.Server.Close()
+	s.mu.Lock()
+	for conn := range s.hijacked {
+		conn.Close()
+		delete(s.hijacked, conn)
+		if closer, ok := s.closers[conn]; ok {
+			delete(s.closers, conn)
+			closer()
+		}
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// drain keeps serving requests for the drain delay, giving load balancers time
+// to notice that the server is no longer ready. Keep-alives are disabled so
+// clients reconnect elsewhere.
+func (s *httpServer) drain(ctx context.Context) {
+	if s.draining.Swap(true) {
+		return
+	}
+	if s.health != nil {
+		s.health.SetState(StateDraining)
+	}
+	if s.config.drainDelay <= 0 {
+		return
+	}
+	s.config.log().Info("draining", "delay", s.config.drainDelay)
+	s.SetKeepAlivesEnabled(false)
+	timer := time.NewTimer(s.config.drainDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// notify handlers that the server is shutting down
+func (s *httpServer) notify() {
+	close(s.shuttingDown)
+	if s.config.cancelRequests {
+		s.cancel()
+	}
+}
+
+func (s *httpServer) track(conn net.Conn) {
+	s.mu.Lock()
+	s.hijacked[conn] = struct{}{}
+	s.mu.Unlock()
+}
+
+// untrack a hijacked connection once it's closed
+func (s *httpServer) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.hijacked, conn)
+	s.mu.Unlock()
+	s.closed(conn)
+}
+
+// opened starts measuring the connection for the metrics
+func (s *httpServer) opened(conn net.Conn) {
+	if s.metrics == nil {
+		return
+	}
+	s.mu.Lock()
+	s.closers[conn] = s.metrics.open()
+	s.mu.Unlock()
+}
+
+// closed stops measuring the connection for the metrics
+func (s *httpServer) closed(conn net.Conn) {
+	s.mu.Lock()
+	closer, ok := s.closers[conn]
+	delete(s.closers, conn)
+	s.mu.Unlock()
+	if ok {
+		closer()
+	}
+}
+
+func (s *httpServer) numHijacked() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.hijacked)
+}
+
+// responseWriter wraps the response writer to observe hijacks and record the
+// response for the access log
+type responseWriter struct {
+	http.ResponseWriter
+	server   *httpServer
+	status   int
+	bytes    int64
+	hijacked bool
+}
+
+var (
+	_ http.Flusher  = (*responseWriter)(nil)
+	_ http.Hijacker = (*responseWriter)(nil)
+	_ io.ReaderFrom = (*responseWriter)(nil)
+)
+
+// Unwrap is used by http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	// Informational responses are followed by the real status
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// ReadFrom keeps sendfile optimizations when copying files
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := io.Copy(w.ResponseWriter, r)
+	w.bytes += n
+	return n, err
+}
+
+// Hijack the connection, tracking it until it's closed
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.hijacked = true
+	w.server.track(conn)
+	return &hijackedConn{Conn: conn, server: w.server}, rw, nil
+}
+
+// hijackedConn stops being tracked once it's closed
+type hijackedConn struct {
+	net.Conn
+	server *httpServer
+	once   sync.Once
+}
+
+func (c *hijackedConn) Close() error {
+	c.once.Do(func() { c.server.untrack(c.Conn) })
+	return c.Conn.Close()
+}
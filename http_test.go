@@ -0,0 +1,258 @@
+package socket_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+// upgrade dials the listener and sends an upgrade request
+func upgrade(t testing.TB, ln net.Listener) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	is := is.New(t)
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	is.NoErr(err)
+	t.Cleanup(func() { conn.Close() })
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: raw\r\n\r\n")
+	is.NoErr(err)
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	is.NoErr(err)
+	is.Equal(line, "upgraded\n")
+	return conn, reader
+}
+
+func TestServeHijackShutdown(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		shuttingDown := socket.ShuttingDown(r.Context())
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			io.WriteString(conn, "upgraded\n")
+			<-shuttingDown
+			io.WriteString(conn, "bye\n")
+		}()
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.Serve(ctx, ln, handler) })
+	_, reader := upgrade(t, ln)
+	cancel()
+	line, err := reader.ReadString('\n')
+	is.NoErr(err)
+	is.Equal(line, "bye\n")
+	is.NoErr(eg.Wait())
+}
+
+func TestServeHijackWaits(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			io.WriteString(conn, "upgraded\n")
+			<-release
+		}()
+	})
+	served := make(chan error, 1)
+	go func() { served <- socket.Serve(ctx, ln, handler) }()
+	upgrade(t, ln)
+	cancel()
+	select {
+	case <-served:
+		is.Fail() // shouldn't return while the upgraded connection is open
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	is.NoErr(<-served)
+}
+
+func TestServeHijackForced(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		// Never close the connection
+		io.WriteString(conn, "upgraded\n")
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.Serve(ctx, ln, handler, socket.WithGracePeriod(50*time.Millisecond))
+	})
+	_, reader := upgrade(t, ln)
+	cancel()
+	is.NoErr(eg.Wait())
+	// The connection should have been closed
+	_, err = reader.ReadByte()
+	is.Equal(err, io.EOF)
+}
+
+// sse streams events until the server shuts down
+func sse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	io.WriteString(w, "data: hello\n\n")
+	w.(http.Flusher).Flush()
+	select {
+	case <-socket.ShuttingDown(r.Context()):
+		io.WriteString(w, "data: bye\n\n")
+	case <-r.Context().Done():
+	}
+}
+
+func TestServeStreamShuttingDown(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.Serve(ctx, ln, http.HandlerFunc(sse)) })
+	res, err := http.Get("http://" + ln.Addr().String())
+	is.NoErr(err)
+	defer res.Body.Close()
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	is.NoErr(err)
+	is.Equal(line, "data: hello\n")
+	cancel()
+	rest, err := io.ReadAll(reader)
+	is.NoErr(err)
+	is.Equal(string(rest), "\ndata: bye\n\n")
+	is.NoErr(eg.Wait())
+}
+
+func TestServeCancelRequests(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.Serve(ctx, ln, handler, socket.WithCancelRequests()) })
+	res, err := http.Get("http://" + ln.Addr().String())
+	is.NoErr(err)
+	defer res.Body.Close()
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	is.NoErr(err)
+	is.Equal(line, "data: hello\n")
+	cancel()
+	is.NoErr(eg.Wait())
+}
+
+func TestServeDrain(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	served := make(chan error, 1)
+	go func() { served <- socket.Serve(ctx, ln, handler, socket.WithDrain(200*time.Millisecond)) }()
+	url := "http://" + ln.Addr().String()
+
+	// Ready before the drain
+	res, err := http.Get(url + "/readyz")
+	is.NoErr(err)
+	res.Body.Close()
+	is.Equal(res.StatusCode, 200)
+	is.Equal(res.Close, false)
+
+	// Not ready, but still serving during the drain
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	res, err = http.Get(url + "/readyz")
+	is.NoErr(err)
+	res.Body.Close()
+	is.Equal(res.StatusCode, 503)
+	res, err = http.Get(url)
+	is.NoErr(err)
+	body, err := io.ReadAll(res.Body)
+	is.NoErr(err)
+	res.Body.Close()
+	is.Equal(string(body), "hello")
+	is.Equal(res.Close, true) // should ask the client to reconnect
+	select {
+	case <-served:
+		is.Fail() // should still be draining
+	default:
+	}
+
+	// Shutdown after the drain
+	is.NoErr(<-served)
+	_, err = http.Get(url)
+	is.True(err != nil)
+}
+
+func TestServeDrainGracePeriod(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(150 * time.Millisecond)
+		w.Write([]byte("hello"))
+	})
+	served := make(chan error, 1)
+	go func() {
+		served <- socket.Serve(ctx, ln, handler,
+			socket.WithDrain(100*time.Millisecond),
+			socket.WithGracePeriod(100*time.Millisecond),
+		)
+	}()
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		res, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		_, err = io.ReadAll(res.Body)
+		return err
+	})
+	<-started
+	cancel()
+	// The request outlasts the grace period, but not the drain and the grace
+	// period together
+	is.NoErr(eg.Wait())
+	is.NoErr(<-served)
+}
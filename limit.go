@@ -0,0 +1,259 @@
+package socket
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LimitListener caps the number of connections that are open at once. Over
+// the limit, Accept waits for a connection to close or, with Reject, closes new
+// connections right away.
+type LimitListener struct {
+	net.Listener
+
+	// Max number of connections that are open at once
+	Max int
+
+	// Reject connections over the limit instead of waiting to accept them
+	Reject bool
+
+	once      sync.Once
+	slots     chan struct{}
+	closeOnce sync.Once
+	closed    chan struct{}
+	rejections
+}
+
+var _ net.Listener = (*LimitListener)(nil)
+
+func (l *LimitListener) init() {
+	l.slots = make(chan struct{}, max(l.Max, 1))
+	l.closed = make(chan struct{})
+}
+
+// Accept waits for a free slot and the next connection
+func (l *LimitListener) Accept() (net.Conn, error) {
+	l.once.Do(l.init)
+	if l.Reject {
+		return l.acceptOrReject()
+	}
+	// Leave connections in the backlog until there's room for them
+	select {
+	case l.slots <- struct{}{}:
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		<-l.slots
+		return nil, err
+	}
+	return &limitConn{Conn: conn, release: l.release}, nil
+}
+
+func (l *LimitListener) acceptOrReject() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case l.slots <- struct{}{}:
+			return &limitConn{Conn: conn, release: l.release}, nil
+		default:
+			conn.Close()
+			l.reject("max_conns")
+		}
+	}
+}
+
+func (l *LimitListener) release() {
+	<-l.slots
+}
+
+// Close the listener, unblocking Accept
+func (l *LimitListener) Close() error {
+	l.once.Do(l.init)
+	l.closeOnce.Do(func() { close(l.closed) })
+	return l.Listener.Close()
+}
+
+// Unwrap returns the underlying listener
+func (l *LimitListener) Unwrap() net.Listener {
+	return l.Listener
+}
+
+// limitConn frees its slot once it's closed
+type limitConn struct {
+	net.Conn
+	release func()
+	once    sync.Once
+}
+
+func (c *limitConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+// NetConn returns the underlying connection
+func (c *limitConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// RateListener limits how fast connections are accepted with a token bucket.
+// Connections over the rate wait in the kernel's backlog.
+type RateListener struct {
+	net.Listener
+
+	// Rate of accepts per second
+	Rate float64
+
+	// Burst of accepts allowed at once. Defaults to 1.
+	Burst int
+
+	mu        sync.Mutex
+	tokens    float64
+	last      time.Time
+	once      sync.Once
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ net.Listener = (*RateListener)(nil)
+
+func (l *RateListener) init() {
+	l.closed = make(chan struct{})
+}
+
+// Accept waits for a token and the next connection
+func (l *RateListener) Accept() (net.Conn, error) {
+	l.once.Do(l.init)
+	if delay := l.reserve(); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-l.closed:
+			return nil, net.ErrClosed
+		}
+	}
+	return l.Listener.Accept()
+}
+
+// reserve a token, returning how long to wait until it's available
+func (l *RateListener) reserve() time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	burst := float64(max(l.Burst, 1))
+	now := time.Now()
+	if l.last.IsZero() {
+		l.tokens = burst
+	} else {
+		l.tokens = min(burst, l.tokens+now.Sub(l.last).Seconds()*l.Rate)
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.Rate * float64(time.Second))
+}
+
+// Close the listener, unblocking Accept
+func (l *RateListener) Close() error {
+	l.once.Do(l.init)
+	l.closeOnce.Do(func() { close(l.closed) })
+	return l.Listener.Close()
+}
+
+// Unwrap returns the underlying listener
+func (l *RateListener) Unwrap() net.Listener {
+	return l.Listener
+}
+
+// rejections reports rejected connections to the metrics
+type rejections struct {
+	hook atomic.Pointer[func(reason string)]
+}
+
+func (r *rejections) onReject(hook func(reason string)) {
+	r.hook.Store(&hook)
+}
+
+func (r *rejections) reject(reason string) {
+	if hook := r.hook.Load(); hook != nil {
+		(*hook)(reason)
+	}
+}
+
+// rejecter is implemented by listeners that reject connections
+type rejecter interface {
+	onReject(hook func(reason string))
+}
+
+// onReject calls the hook for connections rejected by any listener in the
+// chain of wrapped listeners
+func onReject(ln net.Listener, hook func(reason string)) {
+	for ln != nil {
+		if r, ok := ln.(rejecter); ok {
+			r.onReject(hook)
+		}
+		unwrapper, ok := ln.(interface{ Unwrap() net.Listener })
+		if !ok {
+			return
+		}
+		ln = unwrapper.Unwrap()
+	}
+}
+
+// limitListener configures a LimitListener from the address query
+// (e.g. ?max_conns=100&max_conns_reject=1)
+func limitListener(query url.Values) (*LimitListener, error) {
+	value := query.Get("max_conns")
+	if value == "" {
+		return nil, nil
+	}
+	maxConns, err := strconv.Atoi(value)
+	if err != nil || maxConns <= 0 {
+		return nil, fmt.Errorf("socket: invalid max_conns %q", value)
+	}
+	ll := &LimitListener{Max: maxConns}
+	if value := query.Get("max_conns_reject"); value != "" {
+		reject, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("socket: invalid max_conns_reject %q", value)
+		}
+		ll.Reject = reject
+	}
+	return ll, nil
+}
+
+// rateListener configures a RateListener from the address query
+// (e.g. ?accept_rate=100&accept_burst=20)
+func rateListener(query url.Values) (*RateListener, error) {
+	value := query.Get("accept_rate")
+	if value == "" {
+		return nil, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate <= 0 {
+		return nil, fmt.Errorf("socket: invalid accept_rate %q", value)
+	}
+	rl := &RateListener{Rate: rate}
+	if value := query.Get("accept_burst"); value != "" {
+		burst, err := strconv.Atoi(value)
+		if err != nil || burst <= 0 {
+			return nil, fmt.Errorf("socket: invalid accept_burst %q", value)
+		}
+		rl.Burst = burst
+	}
+	return rl, nil
+}
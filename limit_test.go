@@ -0,0 +1,142 @@
+package socket_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+// serveHold serves connections until the client closes them
+func serveHold(t testing.TB, ln net.Listener, options ...socket.Option) <-chan struct{} {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	opened := make(chan struct{}, 10)
+	handler := func(ctx context.Context, conn net.Conn) {
+		opened <- struct{}{}
+		io.Copy(io.Discard, conn)
+	}
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.ServeConn(ctx, ln, handler, options...) })
+	t.Cleanup(func() {
+		cancel()
+		eg.Wait()
+	})
+	return opened
+}
+
+func TestLimitWaits(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(":0?max_conns=1")
+	is.NoErr(err)
+	opened := serveHold(t, ln)
+	first, err := net.Dial("tcp", ln.Addr().String())
+	is.NoErr(err)
+	<-opened
+	second, err := net.Dial("tcp", ln.Addr().String())
+	is.NoErr(err)
+	defer second.Close()
+	select {
+	case <-opened:
+		t.Fatal("expected the second connection to wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	first.Close()
+	select {
+	case <-opened:
+	case <-time.After(time.Second):
+		t.Fatal("expected the second connection to be accepted")
+	}
+}
+
+func TestLimitRejects(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(":0?max_conns=1&max_conns_reject=1")
+	is.NoErr(err)
+	label := socket.Format(ln)
+	metrics := new(socket.Metrics)
+	opened := serveHold(t, ln, socket.WithMetrics(metrics))
+	first, err := net.Dial("tcp", ln.Addr().String())
+	is.NoErr(err)
+	defer first.Close()
+	<-opened
+	second, err := net.Dial("tcp", ln.Addr().String())
+	is.NoErr(err)
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := second.Read(make([]byte, 1))
+	is.True(err != nil)
+	is.Equal(n, 0)
+	// The rejection is counted after the connection is closed
+	var vars map[string]map[string]any
+	for start := time.Now(); time.Since(start) < 500*time.Millisecond; time.Sleep(5 * time.Millisecond) {
+		is.NoErr(json.Unmarshal([]byte(metrics.Var().String()), &vars))
+		if rejected, _ := vars[label]["connections_rejected"].(map[string]any); rejected["max_conns"] == 1.0 {
+			return
+		}
+	}
+	t.Fatalf("expected a rejection, got %v", vars[label]["connections_rejected"])
+}
+
+func TestLimitClose(t *testing.T) {
+	is := is.New(t)
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	ln := &socket.LimitListener{Listener: tcp, Max: 1}
+	conn, err := net.Dial("tcp", tcp.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	_, err = ln.Accept()
+	is.NoErr(err)
+	// The next accept waits for a free slot until the listener is closed
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := ln.Accept()
+		errCh <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	is.NoErr(ln.Close())
+	is.True(errors.Is(<-errCh, net.ErrClosed))
+}
+
+func TestRateListener(t *testing.T) {
+	is := is.New(t)
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	ln := &socket.RateListener{Listener: tcp, Rate: 20, Burst: 1}
+	defer ln.Close()
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("tcp", tcp.Addr().String())
+		is.NoErr(err)
+		defer conn.Close()
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		conn, err := ln.Accept()
+		is.NoErr(err)
+		conn.Close()
+	}
+	// The burst is accepted right away, then one every 50ms
+	is.True(time.Since(start) >= 90*time.Millisecond)
+}
+
+func TestListenInvalidLimits(t *testing.T) {
+	is := is.New(t)
+	for _, addr := range []string{
+		":0?max_conns=abc",
+		":0?max_conns=0",
+		":0?max_conns=1&max_conns_reject=maybe",
+		":0?accept_rate=-1",
+		":0?accept_rate=10&accept_burst=x",
+	} {
+		_, err := socket.Listen(addr)
+		is.True(err != nil) // expected an error
+	}
+}
@@ -0,0 +1,185 @@
+package socket
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ListenConfig configures sockets before they're bound. The zero value listens
+// like Listen. Query parameters on the address are applied on top of the
+// config (e.g. ?reuseport=1&reuseaddr=1).
+type ListenConfig struct {
+	// ReusePort sets SO_REUSEPORT so several sockets can bind the same TCP
+	// address, with the kernel spreading connections across them
+	ReusePort bool
+
+	// ReuseAddr sets SO_REUSEADDR so the TCP address can be bound while old
+	// connections linger in TIME_WAIT. Go already sets it outside of windows.
+	ReuseAddr bool
+
+	// Backlog is the length of the queue of connections waiting to be
+	// accepted. Defaults to the system's limit, like somaxconn on linux.
+	Backlog int
+
+	// SocketOptions tune the listening socket and the connections it accepts
+	SocketOptions
+
+	// Control is called with the raw socket after the options above are set,
+	// like net.ListenConfig.Control
+	Control func(network, address string, c syscall.RawConn) error
+}
+
+// control sets the socket options before the socket is bound
+func (c *ListenConfig) control(network, address string, raw syscall.RawConn) error {
+	if strings.HasPrefix(network, "tcp") {
+		if c.ReusePort && soReusePort == 0 {
+			return fmt.Errorf("socket: reuseport is not supported on %s", runtime.GOOS)
+		}
+		var err error
+		ctrlErr := raw.Control(func(fd uintptr) {
+			if c.ReuseAddr {
+				if err = setsockoptInt(fd, solSocket, soReuseAddr, 1); err != nil {
+					return
+				}
+			}
+			if c.ReusePort {
+				err = setsockoptInt(fd, solSocket, soReusePort, 1)
+			}
+		})
+		if ctrlErr != nil {
+			return ctrlErr
+		} else if err != nil {
+			return err
+		}
+	}
+	if err := c.SocketOptions.control(network, raw, true); err != nil {
+		return err
+	}
+	if c.Control != nil {
+		return c.Control(network, address, raw)
+	}
+	return nil
+}
+
+// bound applies the options that need a listening socket
+func (c *ListenConfig) bound(ln net.Listener) (net.Listener, error) {
+	if c.Backlog > 0 {
+		if err := relisten(ln, c.Backlog); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	if c.Delay {
+		return &delayListener{ln, &c.SocketOptions}, nil
+	}
+	return ln, nil
+}
+
+// relisten calls listen again on the socket to change its backlog
+func relisten(ln net.Listener, backlog int) error {
+	sc, ok := ln.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("socket: unable to set the backlog of %T", ln)
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var listenErr error
+	if err := raw.Control(func(fd uintptr) {
+		listenErr = listenFD(fd, backlog)
+	}); err != nil {
+		return err
+	}
+	if listenErr != nil {
+		return fmt.Errorf("socket: unable to set the backlog: %w", listenErr)
+	}
+	return nil
+}
+
+// withQuery returns a copy of the config with the address query applied
+func (c *ListenConfig) withQuery(query url.Values) (*ListenConfig, error) {
+	config := *c
+	if value := query.Get("reuseport"); value != "" {
+		reusePort, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("socket: invalid reuseport %q", value)
+		}
+		config.ReusePort = reusePort
+	}
+	if value := query.Get("reuseaddr"); value != "" {
+		reuseAddr, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("socket: invalid reuseaddr %q", value)
+		}
+		config.ReuseAddr = reuseAddr
+	}
+	if value := query.Get("backlog"); value != "" {
+		backlog, err := strconv.Atoi(value)
+		if err != nil || backlog <= 0 {
+			return nil, fmt.Errorf("socket: invalid backlog %q", value)
+		}
+		config.Backlog = backlog
+	}
+	options, err := c.SocketOptions.withQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	config.SocketOptions = *options
+	return &config, nil
+}
+
+// ListenReusePort binds n sockets to the same TCP address with SO_REUSEPORT,
+// so each can run its own accept loop with ServeAll. When n is zero or less,
+// it binds one socket per GOMAXPROCS. Random ports like ":0" are resolved by
+// the first socket and shared by the rest.
+func ListenReusePort(addr string, n int) ([]net.Listener, error) {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	if addr == "" {
+		addr = ":0"
+	}
+	url, err := Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	// Only TCP sockets can share a port
+	if url.Scheme != "http" && url.Scheme != "https" {
+		return nil, fmt.Errorf("socket: reuseport needs a tcp address, not %q", addr)
+	}
+	config := &ListenConfig{ReusePort: true}
+	ctx := context.Background()
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		ln, err := config.listenURL(ctx, url)
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return nil, err
+		}
+		if i == 0 {
+			url.Host = ln.Addr().String()
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// ListenAndServeReusePort is a convenience function that combines
+// ListenReusePort and ServeAll
+func ListenAndServeReusePort(ctx context.Context, addr string, n int, handler http.Handler, options ...Option) error {
+	listeners, err := ListenReusePort(addr, n)
+	if err != nil {
+		return err
+	}
+	return ServeAll(ctx, listeners, handler, options...)
+}
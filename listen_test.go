@@ -0,0 +1,95 @@
+package socket_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestListenReusePortQuery(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("reuseport isn't supported on windows")
+	}
+	is := is.New(t)
+	first, err := socket.Listen("127.0.0.1:0?reuseport=1")
+	is.NoErr(err)
+	defer first.Close()
+	second, err := socket.Listen(first.Addr().String() + "?reuseport=1&reuseaddr=1")
+	is.NoErr(err)
+	defer second.Close()
+	is.Equal(first.Addr().String(), second.Addr().String())
+	// Sockets without reuseport can't join
+	_, err = socket.Listen(first.Addr().String())
+	is.True(err != nil) // expected address in use
+}
+
+func TestListenReusePort(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("reuseport isn't supported on windows")
+	}
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	listeners, err := socket.ListenReusePort("127.0.0.1:0", 4)
+	is.NoErr(err)
+	is.Equal(len(listeners), 4)
+	addr := listeners[0].Addr().String()
+	for _, ln := range listeners {
+		is.Equal(ln.Addr().String(), addr)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.ServeAll(ctx, listeners, handler) })
+	for i := 0; i < 8; i++ {
+		is.Equal(get(t, addr), "ok")
+	}
+	cancel()
+	is.NoErr(eg.Wait())
+}
+
+func TestListenReusePortNotTCP(t *testing.T) {
+	is := is.New(t)
+	for _, addr := range []string{
+		"unix://" + filepath.Join(t.TempDir(), "test.sock"),
+		filepath.Join(t.TempDir(), "test.sock"),
+		"fd:3",
+		"mem:reuseport",
+		"stdio:",
+	} {
+		_, err := socket.ListenReusePort(addr, 2)
+		is.True(err != nil)
+		is.Equal(err.Error(), fmt.Sprintf("socket: reuseport needs a tcp address, not %q", addr))
+	}
+}
+
+func TestListenConfigControl(t *testing.T) {
+	is := is.New(t)
+	called := false
+	config := &socket.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			called = true
+			return nil
+		},
+	}
+	ln, err := config.Listen(context.Background(), "127.0.0.1:0")
+	is.NoErr(err)
+	defer ln.Close()
+	is.True(called)
+}
+
+func TestListenInvalidReuse(t *testing.T) {
+	is := is.New(t)
+	_, err := socket.Listen(":0?reuseport=sometimes")
+	is.True(err != nil) // expected an error
+	_, err = socket.Listen(":0?reuseaddr=sometimes")
+	is.True(err != nil) // expected an error
+}
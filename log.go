@@ -0,0 +1,95 @@
+package socket
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// WithLogger logs lifecycle events and routes the http.Server's internal
+// errors, like TLS handshake failures and panics, through the logger. By
+// default, nothing is logged and http.Server errors go to the standard logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// WithAccessLog logs every request with its status, size, latency and peer to
+// the logger from WithLogger
+func WithAccessLog() Option {
+	return func(c *config) {
+		c.accessLog = true
+	}
+}
+
+// log returns the configured logger or one that discards everything
+func (c *config) log() *slog.Logger {
+	if c.logger == nil {
+		return slog.New(discardHandler{})
+	}
+	return c.logger
+}
+
+// discardHandler drops every record
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
+
+// peerKey stores the peer of a connection in the request context
+type peerKey struct{}
+
+// peer identifies the other side of a connection beyond its address
+type peer struct {
+	pid, uid int32
+	ok       bool
+}
+
+// peerContext looks up the peer credentials of unix domain sockets
+func peerContext(ctx context.Context, conn net.Conn) context.Context {
+	pid, uid, ok := peerCred(netConn(conn))
+	return context.WithValue(ctx, peerKey{}, peer{pid, uid, ok})
+}
+
+// netConn unwraps connections wrapped by the listeners in this package
+func netConn(conn net.Conn) net.Conn {
+	for {
+		unwrapper, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return conn
+		}
+		conn = unwrapper.NetConn()
+	}
+}
+
+// logAccess logs a finished request
+func logAccess(logger *slog.Logger, w *responseWriter, r *http.Request, start time.Time) {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.RequestURI()),
+		slog.String("proto", r.Proto),
+		slog.Int("status", status),
+		slog.Int64("bytes", w.bytes),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("remote", r.RemoteAddr),
+	}
+	if w.hijacked {
+		attrs = append(attrs, slog.Bool("hijacked", true))
+	}
+	if peer, _ := r.Context().Value(peerKey{}).(peer); peer.ok {
+		attrs = append(attrs, slog.Int("peer_pid", int(peer.pid)), slog.Int("peer_uid", int(peer.uid)))
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		attrs = append(attrs, slog.String("peer_cn", r.TLS.PeerCertificates[0].Subject.CommonName))
+	}
+	logger.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+}
@@ -0,0 +1,109 @@
+package socket_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+// syncBuffer is a buffer that's safe to log to from multiple goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestServeLogger(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	buf := new(syncBuffer)
+	logger := slog.New(slog.NewTextHandler(buf, nil))
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.Serve(ctx, ln, text("hi"), socket.WithLogger(logger)) })
+	is.Equal(getBody(t, "http://"+ln.Addr().String()), "hi")
+	cancel()
+	is.NoErr(eg.Wait())
+	out := buf.String()
+	is.True(strings.Contains(out, `msg=listening addr=`+socket.Format(ln)))
+	is.True(strings.Contains(out, `msg="shutting down"`))
+	is.True(strings.Contains(out, `msg="shutdown complete"`))
+	is.True(!strings.Contains(out, `msg=request`)) // access log is opt-in
+}
+
+func TestServeErrorLog(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	buf := new(syncBuffer)
+	logger := slog.New(slog.NewTextHandler(buf, nil))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oh no")
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.Serve(ctx, ln, handler, socket.WithLogger(logger)) })
+	_, err = http.Get("http://" + ln.Addr().String())
+	is.True(err != nil)
+	cancel()
+	is.NoErr(eg.Wait())
+	is.True(strings.Contains(buf.String(), `level=ERROR msg="http: panic serving`))
+}
+
+func TestServeAccessLog(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+	ln, err := socket.Listen(socketPath)
+	is.NoErr(err)
+	buf := new(syncBuffer)
+	logger := slog.New(slog.NewTextHandler(buf, nil))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+		io.WriteString(w, "created")
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.Serve(ctx, ln, handler, socket.WithLogger(logger), socket.WithAccessLog())
+	})
+	transport, err := socket.Transport(socketPath)
+	is.NoErr(err)
+	client := &http.Client{Transport: transport, Timeout: time.Second}
+	res, err := client.Get("http://localhost/a?b=c")
+	is.NoErr(err)
+	res.Body.Close()
+	cancel()
+	is.NoErr(eg.Wait())
+	out := buf.String()
+	is.True(strings.Contains(out, `msg=request method=GET path="/a?b=c" proto=HTTP/1.1 status=201 bytes=7 latency=`))
+	if runtime.GOOS == "linux" {
+		is.True(strings.Contains(out, `peer_uid=`)) // unix sockets know their peer
+	}
+}
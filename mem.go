@@ -0,0 +1,335 @@
+package socket
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// memBacklog is how many connections can wait to be accepted
+	memBacklog = 128
+	// memBufferSize is how many bytes can be written before the peer reads
+	memBufferSize = 64 << 10
+)
+
+// memListeners is the registry of in-memory listeners by name
+var memListeners = struct {
+	sync.Mutex
+	byName map[string]*memListener
+}{byName: map[string]*memListener{}}
+
+// listenMem registers an in-memory listener. Connections from dialing the name
+// are buffered in-memory pipes, so tests can run through Serve without ports.
+func listenMem(name string) (net.Listener, error) {
+	memListeners.Lock()
+	defer memListeners.Unlock()
+	if _, ok := memListeners.byName[name]; ok {
+		return nil, fmt.Errorf("socket: mem:%s is already in use", name)
+	}
+	ln := &memListener{
+		addr:   memAddr(name),
+		conns:  make(chan net.Conn, memBacklog),
+		closed: make(chan struct{}),
+	}
+	memListeners.byName[name] = ln
+	return ln, nil
+}
+
+// dialMem connects to the in-memory listener with the name
+func dialMem(ctx context.Context, name string) (net.Conn, error) {
+	memListeners.Lock()
+	ln, ok := memListeners.byName[name]
+	memListeners.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("socket: nothing is listening on mem:%s", name)
+	}
+	client, server := memPipe(ln.addr)
+	select {
+	case ln.conns <- server:
+		return client, nil
+	case <-ln.closed:
+		return nil, fmt.Errorf("socket: nothing is listening on mem:%s", name)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// memListener accepts in-memory connections
+type memListener struct {
+	addr   memAddr
+	conns  chan net.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+var _ net.Listener = (*memListener)(nil)
+
+func (l *memListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close the listener, freeing the name and refusing connections that weren't
+// accepted yet
+func (l *memListener) Close() error {
+	l.once.Do(func() {
+		memListeners.Lock()
+		delete(memListeners.byName, string(l.addr))
+		memListeners.Unlock()
+		close(l.closed)
+		for {
+			select {
+			case conn := <-l.conns:
+				conn.Close()
+			default:
+				return
+			}
+		}
+	})
+	return nil
+}
+
+func (l *memListener) Addr() net.Addr {
+	return l.addr
+}
+
+// memAddr is the name of an in-memory listener
+type memAddr string
+
+func (a memAddr) Network() string { return "mem" }
+func (a memAddr) String() string  { return "mem:" + string(a) }
+
+// memPipe creates both ends of an in-memory connection
+func memPipe(addr net.Addr) (client, server *memConn) {
+	up, down := newMemBuffer(), newMemBuffer()
+	client = &memConn{addr: addr, in: down, out: up, readDeadline: newDeadline(), writeDeadline: newDeadline()}
+	server = &memConn{addr: addr, in: up, out: down, readDeadline: newDeadline(), writeDeadline: newDeadline()}
+	return client, server
+}
+
+// memConn is one end of an in-memory connection
+type memConn struct {
+	addr          net.Addr
+	in            *memBuffer
+	out           *memBuffer
+	readDeadline  *deadline
+	writeDeadline *deadline
+}
+
+var _ net.Conn = (*memConn)(nil)
+
+func (c *memConn) Read(p []byte) (int, error) {
+	for {
+		if isDone(c.readDeadline.done()) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		n, wait, err := c.in.read(p)
+		if wait == nil {
+			return n, err
+		}
+		select {
+		case <-wait:
+		case <-c.readDeadline.done():
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+func (c *memConn) Write(p []byte) (int, error) {
+	var written int
+	for {
+		if isDone(c.writeDeadline.done()) {
+			return written, os.ErrDeadlineExceeded
+		}
+		n, wait, err := c.out.write(p[written:])
+		written += n
+		if wait == nil {
+			return written, err
+		}
+		select {
+		case <-wait:
+		case <-c.writeDeadline.done():
+			return written, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+// CloseWrite shuts down the writing side, so the peer reads io.EOF
+func (c *memConn) CloseWrite() error {
+	c.out.closeWrite()
+	return nil
+}
+
+// Close both sides of the connection
+func (c *memConn) Close() error {
+	c.in.closeRead()
+	c.out.closeWrite()
+	return nil
+}
+
+func (c *memConn) LocalAddr() net.Addr  { return c.addr }
+func (c *memConn) RemoteAddr() net.Addr { return c.addr }
+
+func (c *memConn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *memConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *memConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// memBuffer is one direction of an in-memory connection
+type memBuffer struct {
+	mu          sync.Mutex
+	buf         bytes.Buffer
+	changed     chan struct{} // closed whenever the buffer changes
+	readClosed  bool
+	writeClosed bool
+}
+
+func newMemBuffer() *memBuffer {
+	return &memBuffer{changed: make(chan struct{})}
+}
+
+// notify the waiters that the buffer changed. Must be called with the lock.
+func (b *memBuffer) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// read from the buffer or return a channel to wait on
+func (b *memBuffer) read(p []byte) (int, <-chan struct{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.readClosed:
+		return 0, nil, net.ErrClosed
+	case b.buf.Len() > 0:
+		n, _ := b.buf.Read(p)
+		b.notify()
+		return n, nil, nil
+	case b.writeClosed:
+		return 0, nil, io.EOF
+	case len(p) == 0:
+		return 0, nil, nil
+	default:
+		return 0, b.changed, nil
+	}
+}
+
+// write as much as fits in the buffer, returning a channel to wait on for the
+// rest
+func (b *memBuffer) write(p []byte) (int, <-chan struct{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.writeClosed:
+		return 0, nil, net.ErrClosed
+	case b.readClosed:
+		return 0, nil, io.ErrClosedPipe
+	case len(p) == 0:
+		return 0, nil, nil
+	}
+	n := min(len(p), memBufferSize-b.buf.Len())
+	if n > 0 {
+		b.buf.Write(p[:n])
+		b.notify()
+	}
+	if n == len(p) {
+		return n, nil, nil
+	}
+	return n, b.changed, nil
+}
+
+func (b *memBuffer) closeRead() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.readClosed {
+		b.readClosed = true
+		b.buf.Reset()
+		b.notify()
+	}
+}
+
+func (b *memBuffer) closeWrite() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.writeClosed {
+		b.writeClosed = true
+		b.notify()
+	}
+}
+
+// deadline closes a channel once the time passes, like the deadlines of
+// net.Pipe
+type deadline struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	cancel chan struct{}
+}
+
+func newDeadline() *deadline {
+	return &deadline{cancel: make(chan struct{})}
+}
+
+// set the deadline. A zero time means no deadline.
+func (d *deadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil && !d.timer.Stop() {
+		<-d.cancel // Wait for the timer to close the channel
+	}
+	d.timer = nil
+	expired := isDone(d.cancel)
+	if t.IsZero() {
+		if expired {
+			d.cancel = make(chan struct{})
+		}
+		return
+	}
+	if wait := time.Until(t); wait > 0 {
+		if expired {
+			d.cancel = make(chan struct{})
+		}
+		cancel := d.cancel
+		d.timer = time.AfterFunc(wait, func() { close(cancel) })
+		return
+	}
+	if !expired {
+		close(d.cancel)
+	}
+}
+
+// done returns a channel that's closed once the deadline passes
+func (d *deadline) done() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cancel
+}
+
+func isDone(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
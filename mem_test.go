@@ -0,0 +1,126 @@
+package socket_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"github.com/matthewmueller/socket/sockettest"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestMemServe(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	ln, err := socket.Listen("mem:serve")
+	is.NoErr(err)
+	is.Equal(socket.Format(ln), "mem:serve")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.Serve(ctx, ln, handler) })
+	transport, err := socket.Transport("mem:serve")
+	is.NoErr(err)
+	client := &http.Client{Transport: transport, Timeout: time.Second}
+	for _, path := range []string{"/a", "/b"} {
+		res, err := client.Get("http://api" + path)
+		is.NoErr(err)
+		body, err := io.ReadAll(res.Body)
+		is.NoErr(err)
+		res.Body.Close()
+		is.Equal(string(body), path)
+	}
+	cancel()
+	is.NoErr(eg.Wait())
+	// The name is free again
+	_, err = socket.Dial(context.Background(), "mem:serve")
+	is.True(err != nil) // expected nothing to be listening
+}
+
+func TestMemAddrInUse(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+	ln, err := socket.Listen("mem:in-use")
+	is.NoErr(err)
+	_, err = socket.Listen("mem:in-use")
+	is.True(err != nil) // expected the name to be in use
+	is.NoErr(ln.Close())
+	ln, err = socket.Listen("mem:in-use")
+	is.NoErr(err)
+	is.NoErr(ln.Close())
+}
+
+func TestMemDeadline(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+	client, server := sockettest.Pipe(t)
+	is.NoErr(server.SetReadDeadline(time.Now().Add(10 * time.Millisecond)))
+	_, err := server.Read(make([]byte, 1))
+	is.True(errors.Is(err, os.ErrDeadlineExceeded))
+	var ne net.Error
+	is.True(errors.As(err, &ne) && ne.Timeout())
+	// Clearing the deadline lets reads continue
+	is.NoErr(server.SetReadDeadline(time.Time{}))
+	_, err = client.Write([]byte("x"))
+	is.NoErr(err)
+	buf := make([]byte, 1)
+	_, err = server.Read(buf)
+	is.NoErr(err)
+	is.Equal(string(buf), "x")
+}
+
+func TestMemBackpressure(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+	client, server := sockettest.Pipe(t)
+	// Writes block once the buffer is full
+	is.NoErr(client.SetWriteDeadline(time.Now().Add(20 * time.Millisecond)))
+	data := bytes.Repeat([]byte("x"), 1<<20)
+	n, err := client.Write(data)
+	is.True(errors.Is(err, os.ErrDeadlineExceeded))
+	is.True(n > 0 && n < len(data))
+	// And continue as the peer reads
+	is.NoErr(client.SetWriteDeadline(time.Time{}))
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		_, err := client.Write(data[n:])
+		client.Close()
+		return err
+	})
+	read, err := io.ReadAll(server)
+	is.NoErr(err)
+	is.NoErr(eg.Wait())
+	is.Equal(len(read), len(data))
+}
+
+func TestMemHalfClose(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+	client, server := sockettest.Pipe(t)
+	_, err := client.Write([]byte("ping"))
+	is.NoErr(err)
+	is.NoErr(client.(interface{ CloseWrite() error }).CloseWrite())
+	// The server reads until EOF, then can still reply
+	req, err := io.ReadAll(server)
+	is.NoErr(err)
+	is.Equal(string(req), "ping")
+	_, err = server.Write([]byte("pong"))
+	is.NoErr(err)
+	is.NoErr(server.Close())
+	res, err := io.ReadAll(client)
+	is.NoErr(err)
+	is.Equal(string(res), "pong")
+	// Writing to a closed peer fails
+	_, err = server.Write([]byte("x"))
+	is.True(err != nil)
+}
@@ -0,0 +1,321 @@
+package socket
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// durationBuckets are the upper bounds in seconds of the connection duration
+// histogram
+var durationBuckets = []float64{.005, .01, .05, .1, .5, 1, 5, 10, 30, 60, 300}
+
+// Metrics collects connection and request metrics for every listener it's
+// passed to with WithMetrics. Listeners are labeled with Format. Metrics are
+// exposed through expvar with Var and in the Prometheus text format with
+// ServeHTTP.
+type Metrics struct {
+	mu        sync.Mutex
+	listeners map[string]*listenerMetrics
+}
+
+var _ http.Handler = (*Metrics)(nil)
+
+// listenerMetrics are the metrics of a single listener
+type listenerMetrics struct {
+	active       atomic.Int64
+	accepted     atomic.Uint64
+	acceptErrors atomic.Uint64
+	inflight     atomic.Int64
+	requests     atomic.Uint64
+	shutdown     atomic.Int64 // nanoseconds
+	panics       atomic.Uint64
+	duration     histogram
+
+	mu       sync.Mutex
+	rejected map[string]uint64 // by reason
+}
+
+// listener returns the metrics for the listener, creating them if needed
+func (m *Metrics) listener(label string) *listenerMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.listeners == nil {
+		m.listeners = map[string]*listenerMetrics{}
+	}
+	lm, ok := m.listeners[label]
+	if !ok {
+		lm = &listenerMetrics{duration: histogram{counts: make([]uint64, len(durationBuckets))}}
+		m.listeners[label] = lm
+	}
+	return lm
+}
+
+// reject counts a connection rejected by a listener like LimitListener
+func (lm *listenerMetrics) reject(reason string) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if lm.rejected == nil {
+		lm.rejected = map[string]uint64{}
+	}
+	lm.rejected[reason]++
+}
+
+// rejections returns the rejected counts in reason order
+func (lm *listenerMetrics) rejections() (reasons []string, counts []uint64) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	for reason := range lm.rejected {
+		reasons = append(reasons, reason)
+	}
+	slices.Sort(reasons)
+	for _, reason := range reasons {
+		counts = append(counts, lm.rejected[reason])
+	}
+	return reasons, counts
+}
+
+// snapshot the listeners in label order
+func (m *Metrics) snapshot() (labels []string, listeners []*listenerMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for label := range m.listeners {
+		labels = append(labels, label)
+	}
+	slices.Sort(labels)
+	for _, label := range labels {
+		listeners = append(listeners, m.listeners[label])
+	}
+	return labels, listeners
+}
+
+// open records a new connection and returns a function to record it closing
+func (lm *listenerMetrics) open() (close func()) {
+	lm.active.Add(1)
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			lm.active.Add(-1)
+			lm.duration.observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// Var returns the metrics as an expvar variable, for example:
+//
+//	expvar.Publish("socket", metrics.Var())
+func (m *Metrics) Var() expvar.Var {
+	return expvar.Func(func() any {
+		labels, listeners := m.snapshot()
+		out := make(map[string]map[string]any, len(labels))
+		for i, lm := range listeners {
+			sum, count, _ := lm.duration.read()
+			reasons, counts := lm.rejections()
+			rejected := make(map[string]uint64, len(reasons))
+			for j, reason := range reasons {
+				rejected[reason] = counts[j]
+			}
+			out[labels[i]] = map[string]any{
+				"connections_active":        lm.active.Load(),
+				"connections_accepted":      lm.accepted.Load(),
+				"accept_errors":             lm.acceptErrors.Load(),
+				"connections_rejected":      rejected,
+				"connection_duration_sum":   sum,
+				"connection_duration_count": count,
+				"requests_in_flight":        lm.inflight.Load(),
+				"requests":                  lm.requests.Load(),
+				"shutdown_duration_seconds": time.Duration(lm.shutdown.Load()).Seconds(),
+				"panics":                    lm.panics.Load(),
+			}
+		}
+		return out
+	})
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	labels, listeners := m.snapshot()
+	p := &promWriter{w: w}
+	p.family("socket_connections_active", "gauge", "Number of open connections.")
+	for i, lm := range listeners {
+		p.sample("socket_connections_active", labels[i], "", float64(lm.active.Load()))
+	}
+	p.family("socket_connections_accepted_total", "counter", "Number of accepted connections.")
+	for i, lm := range listeners {
+		p.sample("socket_connections_accepted_total", labels[i], "", float64(lm.accepted.Load()))
+	}
+	p.family("socket_accept_errors_total", "counter", "Number of errors accepting connections.")
+	for i, lm := range listeners {
+		p.sample("socket_accept_errors_total", labels[i], "", float64(lm.acceptErrors.Load()))
+	}
+	p.family("socket_connections_rejected_total", "counter", "Number of connections rejected by the listener.")
+	for i, lm := range listeners {
+		reasons, counts := lm.rejections()
+		for j, reason := range reasons {
+			p.labeled("socket_connections_rejected_total", labels[i], "reason", reason, float64(counts[j]))
+		}
+	}
+	p.family("socket_connection_duration_seconds", "histogram", "How long connections stayed open.")
+	for i, lm := range listeners {
+		sum, count, counts := lm.duration.read()
+		var cumulative uint64
+		for j, le := range durationBuckets {
+			cumulative += counts[j]
+			p.sample("socket_connection_duration_seconds_bucket", labels[i], formatFloat(le), float64(cumulative))
+		}
+		p.sample("socket_connection_duration_seconds_bucket", labels[i], "+Inf", float64(count))
+		p.sample("socket_connection_duration_seconds_sum", labels[i], "", sum)
+		p.sample("socket_connection_duration_seconds_count", labels[i], "", float64(count))
+	}
+	p.family("socket_requests_in_flight", "gauge", "Number of HTTP requests being served.")
+	for i, lm := range listeners {
+		p.sample("socket_requests_in_flight", labels[i], "", float64(lm.inflight.Load()))
+	}
+	p.family("socket_requests_total", "counter", "Number of HTTP requests served.")
+	for i, lm := range listeners {
+		p.sample("socket_requests_total", labels[i], "", float64(lm.requests.Load()))
+	}
+	p.family("socket_shutdown_duration_seconds", "gauge", "How long the last shutdown took.")
+	for i, lm := range listeners {
+		p.sample("socket_shutdown_duration_seconds", labels[i], "", time.Duration(lm.shutdown.Load()).Seconds())
+	}
+	p.family("socket_panics_total", "counter", "Number of HTTP handlers that panicked.")
+	for i, lm := range listeners {
+		p.sample("socket_panics_total", labels[i], "", float64(lm.panics.Load()))
+	}
+	return p.n, p.err
+}
+
+// promWriter writes the Prometheus text format, keeping the first error
+type promWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (p *promWriter) printf(format string, args ...any) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.n += int64(n)
+	p.err = err
+}
+
+func (p *promWriter) family(name, kind, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (p *promWriter) sample(name, listener, le string, value float64) {
+	if le != "" {
+		p.labeled(name, listener, "le", le, value)
+		return
+	}
+	p.printf("%s{listener=%s} %s\n", name, quoteLabel(listener), formatFloat(value))
+}
+
+// labeled writes a sample with a label besides the listener
+func (p *promWriter) labeled(name, listener, key, value string, sample float64) {
+	p.printf("%s{listener=%s,%s=%s} %s\n", name, quoteLabel(listener), key, quoteLabel(value), formatFloat(sample))
+}
+
+// labelEscaper escapes label values in the Prometheus text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// histogram counts observations into durationBuckets
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.count++
+	h.sum += value
+	for i, le := range durationBuckets {
+		if value <= le {
+			h.counts[i]++
+			break
+		}
+	}
+}
+
+func (h *histogram) read() (sum float64, count uint64, counts []uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sum, h.count, slices.Clone(h.counts)
+}
+
+// metricsListener counts accepts and accept errors. Connections are only
+// wrapped to track their lifetime when the server can't track them itself.
+type metricsListener struct {
+	net.Listener
+	metrics   *listenerMetrics
+	wrapConns bool
+}
+
+// Unwrap returns the underlying listener
+func (l *metricsListener) Unwrap() net.Listener {
+	return l.Listener
+}
+
+func (l *metricsListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		if !errors.Is(err, net.ErrClosed) {
+			l.metrics.acceptErrors.Add(1)
+		}
+		return nil, err
+	}
+	l.metrics.accepted.Add(1)
+	if !l.wrapConns {
+		return conn, nil
+	}
+	return &metricsConn{Conn: conn, close: l.metrics.open()}, nil
+}
+
+// metricsConn records when the connection closes
+type metricsConn struct {
+	net.Conn
+	close func()
+}
+
+func (c *metricsConn) Close() error {
+	c.close()
+	return c.Conn.Close()
+}
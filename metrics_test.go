@@ -0,0 +1,87 @@
+package socket_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestMetricsServe(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	label := socket.Format(ln)
+	metrics := new(socket.Metrics)
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.Serve(ctx, ln, text("hi"), socket.WithMetrics(metrics)) })
+	is.Equal(getBody(t, "http://"+ln.Addr().String()), "hi")
+	is.Equal(getBody(t, "http://"+ln.Addr().String()), "hi")
+	cancel()
+	is.NoErr(eg.Wait())
+
+	// Idle connections report closing asynchronously after the shutdown
+	var out string
+	for attempts := 0; attempts < 50; attempts++ {
+		rec := httptest.NewRecorder()
+		metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		out = rec.Body.String()
+		if strings.Contains(out, `socket_connections_active{listener="`+label+`"} 0`) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	is.True(strings.Contains(out, "# TYPE socket_connections_active gauge\n"))
+	is.True(strings.Contains(out, `socket_connections_active{listener="`+label+`"} 0`))
+	is.True(strings.Contains(out, `socket_connections_accepted_total{listener="`+label+`"} 1`))
+	is.True(strings.Contains(out, `socket_requests_total{listener="`+label+`"} 2`))
+	is.True(strings.Contains(out, `socket_requests_in_flight{listener="`+label+`"} 0`))
+	is.True(strings.Contains(out, `socket_connection_duration_seconds_count{listener="`+label+`"} 1`))
+	is.True(strings.Contains(out, `socket_connection_duration_seconds_bucket{listener="`+label+`",le="+Inf"} 1`))
+	is.True(strings.Contains(out, `socket_shutdown_duration_seconds{listener="`+label+`"} `))
+}
+
+func TestMetricsServeConn(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	label := socket.Format(ln)
+	metrics := new(socket.Metrics)
+	opened := make(chan struct{})
+	handler := func(ctx context.Context, conn net.Conn) {
+		opened <- struct{}{}
+		bufio.NewReader(conn).ReadString('\n')
+	}
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.ServeConn(ctx, ln, handler, socket.WithMetrics(metrics)) })
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	is.NoErr(err)
+	<-opened
+
+	// Check the expvar output while the connection is open
+	var vars map[string]map[string]any
+	is.NoErr(json.Unmarshal([]byte(metrics.Var().String()), &vars))
+	is.Equal(vars[label]["connections_active"], 1.0)
+	is.Equal(vars[label]["connections_accepted"], 1.0)
+
+	conn.Write([]byte("bye\n"))
+	conn.Close()
+	cancel()
+	is.NoErr(eg.Wait())
+	is.NoErr(json.Unmarshal([]byte(metrics.Var().String()), &vars))
+	is.Equal(vars[label]["connections_active"], 0.0)
+	is.Equal(vars[label]["connection_duration_count"], 1.0)
+}
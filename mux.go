@@ -0,0 +1,251 @@
+package socket
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMuxTimeout is how long to wait for the bytes a matcher needs
+const defaultMuxTimeout = 10 * time.Second
+
+// Matcher reports whether a connection belongs to a listener based on its
+// first bytes. Matchers should read as little as possible, the bytes they read
+// are replayed to the next matcher and to the connection's eventual reader.
+type Matcher func(r io.Reader) bool
+
+// Mux splits a listener into child listeners by sniffing the first bytes of
+// each connection. This allows one port or unix socket to serve HTTP/1, h2c,
+// TLS and custom protocols side-by-side. Register matchers with Match before
+// calling Accept on any of the children.
+type Mux struct {
+	// Listener to split
+	Listener net.Listener
+
+	// Timeout to sniff the first bytes of a connection. Connections that time
+	// out are passed to a MatchAny listener if there is one. Defaults to 10
+	// seconds.
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	once     sync.Once
+	children []*muxListener
+	closed   chan struct{}
+	err      error
+}
+
+// Match returns a child listener for connections where any of the matchers
+// match. Matchers are tried in the order they were registered across all the
+// children. Connections that don't match any child are closed.
+func (m *Mux) Match(matchers ...Matcher) net.Listener {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+	child := &muxListener{
+		mux:      m,
+		matchers: matchers,
+		conns:    make(chan net.Conn),
+		closed:   make(chan struct{}),
+	}
+	m.children = append(m.children, child)
+	return child
+}
+
+// Close the parent listener and all of its children
+func (m *Mux) Close() error {
+	m.mu.Lock()
+	m.init()
+	children := m.children
+	m.mu.Unlock()
+	for _, child := range children {
+		child.Close()
+	}
+	return m.Listener.Close()
+}
+
+func (m *Mux) init() {
+	if m.closed == nil {
+		m.closed = make(chan struct{})
+	}
+}
+
+// serve accepts connections from the parent and dispatches them
+func (m *Mux) serve() {
+	for {
+		conn, err := acceptBackoff(m.Listener)
+		if err != nil {
+			m.err = err
+			close(m.closed)
+			return
+		}
+		go m.dispatch(conn)
+	}
+}
+
+// dispatch sniffs the connection and sends it to the first matching child
+func (m *Mux) dispatch(conn net.Conn) {
+	timeout := m.Timeout
+	if timeout <= 0 {
+		timeout = defaultMuxTimeout
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return
+	}
+	sniff := &sniffer{conn: conn}
+	m.mu.Lock()
+	children := m.children
+	m.mu.Unlock()
+	for _, child := range children {
+		for _, match := range child.matchers {
+			if !match(&replayReader{sniff: sniff}) {
+				continue
+			}
+			if err := conn.SetReadDeadline(time.Time{}); err != nil {
+				conn.Close()
+				return
+			}
+			child.deliver(&muxConn{conn, io.MultiReader(bytes.NewReader(sniff.buf.Bytes()), conn)})
+			return
+		}
+	}
+	conn.Close()
+}
+
+// muxListener is a child listener of the mux
+type muxListener struct {
+	mux       *Mux
+	matchers  []Matcher
+	conns     chan net.Conn
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ net.Listener = (*muxListener)(nil)
+
+func (l *muxListener) Accept() (net.Conn, error) {
+	l.mux.once.Do(func() { go l.mux.serve() })
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	case <-l.mux.closed:
+		return nil, l.mux.err
+	}
+}
+
+func (l *muxListener) deliver(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.closed:
+		conn.Close()
+	case <-l.mux.closed:
+		conn.Close()
+	}
+}
+
+// Close the child listener. The parent and the other children keep running.
+func (l *muxListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *muxListener) Addr() net.Addr {
+	return l.mux.Listener.Addr()
+}
+
+// muxConn replays the sniffed bytes before reading from the connection
+type muxConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *muxConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// sniffer records the bytes read by matchers
+type sniffer struct {
+	conn net.Conn
+	buf  bytes.Buffer
+}
+
+// replayReader reads the recorded bytes, then reads and records more
+type replayReader struct {
+	sniff  *sniffer
+	offset int
+}
+
+func (r *replayReader) Read(p []byte) (int, error) {
+	if r.offset < r.sniff.buf.Len() {
+		n := copy(p, r.sniff.buf.Bytes()[r.offset:])
+		r.offset += n
+		return n, nil
+	}
+	n, err := r.sniff.conn.Read(p)
+	r.sniff.buf.Write(p[:n])
+	r.offset += n
+	return n, err
+}
+
+// MatchAny matches every connection. Register it last as a fallback.
+func MatchAny(io.Reader) bool {
+	return true
+}
+
+// MatchPrefix matches connections that start with any of the prefixes
+func MatchPrefix(prefixes ...string) Matcher {
+	return func(r io.Reader) bool {
+		var buf []byte
+		b := make([]byte, 1)
+		for {
+			// Read one byte at a time and stop at the first byte that no prefix
+			// accepts, so short messages don't wait for bytes that never arrive
+			accepted := false
+			for _, prefix := range prefixes {
+				if !strings.HasPrefix(prefix, string(buf)) {
+					continue
+				}
+				if len(prefix) == len(buf) {
+					return true
+				}
+				accepted = true
+			}
+			if !accepted {
+				return false
+			}
+			if _, err := io.ReadFull(r, b); err != nil {
+				return false
+			}
+			buf = append(buf, b[0])
+		}
+	}
+}
+
+// http2Preface is sent by HTTP/2 clients with prior knowledge (h2c)
+const http2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// MatchHTTP1 matches HTTP/1 requests by their method
+var MatchHTTP1 = MatchPrefix("GET ", "HEAD ", "POST ", "PUT ", "DELETE ", "CONNECT ", "OPTIONS ", "TRACE ", "PATCH ")
+
+// MatchHTTP2 matches HTTP/2 connections with prior knowledge (h2c)
+var MatchHTTP2 = MatchPrefix(http2Preface)
+
+// MatchTLS matches a TLS ClientHello
+func MatchTLS(r io.Reader) bool {
+	// Record layer: handshake (0x16), version 3.x, 2 byte length, then the
+	// handshake type (0x01 for ClientHello). Check the first byte on its own
+	// so short messages from other protocols don't wait for more bytes.
+	header := make([]byte, 6)
+	if _, err := io.ReadFull(r, header[:1]); err != nil || header[0] != 0x16 {
+		return false
+	}
+	if _, err := io.ReadFull(r, header[1:]); err != nil {
+		return false
+	}
+	return header[1] == 0x03 && header[5] == 0x01
+}
@@ -0,0 +1,170 @@
+package socket_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestMux(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	mux := &socket.Mux{Listener: ln}
+	defer mux.Close()
+	httpLn := mux.Match(socket.MatchHTTP1)
+	tlsLn := mux.Match(socket.MatchTLS)
+	lineLn := mux.Match(socket.MatchPrefix("PING"))
+
+	// Serve HTTP
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.Serve(ctx, httpLn, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("http"))
+		}))
+	})
+
+	// Serve TLS
+	tlsServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tls"))
+	}))
+	tlsServer.Listener = tlsLn
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	// Serve a line protocol
+	go func() {
+		for {
+			conn, err := lineLn.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			if line == "PING\n" {
+				conn.Write([]byte("PONG\n"))
+			}
+			conn.Close()
+		}
+	}()
+
+	// HTTP
+	res, err := http.Get("http://" + ln.Addr().String())
+	is.NoErr(err)
+	body, err := io.ReadAll(res.Body)
+	is.NoErr(err)
+	is.Equal(string(body), "http")
+
+	// TLS
+	res, err = tlsServer.Client().Get("https://" + ln.Addr().String())
+	is.NoErr(err)
+	body, err = io.ReadAll(res.Body)
+	is.NoErr(err)
+	is.Equal(string(body), "tls")
+
+	// Line protocol
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("PING\n"))
+	is.NoErr(err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	is.NoErr(err)
+	is.Equal(line, "PONG\n")
+
+	cancel()
+	is.NoErr(eg.Wait())
+}
+
+func TestMuxHTTP2Preface(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	mux := &socket.Mux{Listener: ln}
+	defer mux.Close()
+	h1 := mux.Match(socket.MatchHTTP1)
+	h2 := mux.Match(socket.MatchHTTP2)
+	defer h1.Close()
+	preface := "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte(preface))
+	is.NoErr(err)
+	accepted, err := h2.Accept()
+	is.NoErr(err)
+	defer accepted.Close()
+	// The sniffed bytes are replayed
+	buf := make([]byte, len(preface))
+	_, err = io.ReadFull(accepted, buf)
+	is.NoErr(err)
+	is.Equal(string(buf), preface)
+}
+
+func TestMuxShortPrefix(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	mux := &socket.Mux{Listener: ln}
+	defer mux.Close()
+	mux.Match(socket.MatchHTTP1, socket.MatchHTTP2)
+	lineLn := mux.Match(socket.MatchPrefix("PING"))
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("PING\n"))
+	is.NoErr(err)
+	// The HTTP/2 preface is longer than the message, but the mismatch is seen
+	// without waiting for the timeout
+	start := time.Now()
+	accepted, err := lineLn.Accept()
+	is.NoErr(err)
+	defer accepted.Close()
+	is.True(time.Since(start) < time.Second)
+	line, err := bufio.NewReader(accepted).ReadString('\n')
+	is.NoErr(err)
+	is.Equal(line, "PING\n")
+}
+
+func TestMuxUnmatched(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	mux := &socket.Mux{Listener: ln, Timeout: 100 * time.Millisecond}
+	defer mux.Close()
+	httpLn := mux.Match(socket.MatchHTTP1)
+	go httpLn.Accept()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("NOPE\n"))
+	is.NoErr(err)
+	// The connection should be closed without a response
+	n, err := conn.Read(make([]byte, 1))
+	is.True(err != nil)
+	is.Equal(n, 0)
+}
+
+func TestMuxClose(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	mux := &socket.Mux{Listener: ln}
+	a := mux.Match(socket.MatchHTTP1)
+	b := mux.Match(socket.MatchAny)
+	is.NoErr(mux.Close())
+	_, err = a.Accept()
+	is.True(err != nil)
+	_, err = b.Accept()
+	is.True(err != nil)
+}
@@ -0,0 +1,89 @@
+package socket
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Option configures how a server is run
+type Option func(*config)
+
+type config struct {
+	gracePeriod    time.Duration
+	cancelRequests bool
+	drainDelay     time.Duration
+	health         *Health
+	reload         func(ctx context.Context) (http.Handler, error)
+	logger         *slog.Logger
+	accessLog      bool
+	metrics        *Metrics
+	recover        bool
+	reportPanic    PanicReporter
+}
+
+func newConfig(options []Option) *config {
+	config := new(config)
+	for _, option := range options {
+		option(config)
+	}
+	return config
+}
+
+// WithGracePeriod limits how long a graceful shutdown waits for connections to
+// finish before closing them. The grace period starts after the drain delay of
+// WithDrain. By default, shutdown waits until every connection has finished or
+// until a second interrupt is received.
+func WithGracePeriod(gracePeriod time.Duration) Option {
+	return func(c *config) {
+		c.gracePeriod = gracePeriod
+	}
+}
+
+// WithCancelRequests cancels the context of every in-flight request when a
+// graceful shutdown begins. This lets streaming handlers that only watch
+// r.Context(), like Server-Sent Events, return without a forced shutdown.
+// Handlers that need to finish their work should watch ShuttingDown instead.
+func WithCancelRequests() Option {
+	return func(c *config) {
+		c.cancelRequests = true
+	}
+}
+
+// WithDrain keeps serving requests for the delay after the context is canceled
+// and before shutting down. During the drain, /readyz fails and responses close
+// their connections, giving load balancers time to stop routing to the server.
+// The health endpoints are mounted on the main listener unless WithHealth
+// says otherwise.
+func WithDrain(delay time.Duration) Option {
+	return func(c *config) {
+		c.drainDelay = delay
+	}
+}
+
+// WithHealth serves the health endpoints and drives their readiness with the
+// server's lifecycle. The endpoints are mounted on the main listener unless
+// the health has its own address.
+func WithHealth(health *Health) Option {
+	return func(c *config) {
+		c.health = health
+	}
+}
+
+// WithReload swaps in the handler returned by reload whenever the process
+// receives SIGHUP. The current handler is kept if reload returns an error.
+// Listeners served together, like with ServeAll, reload once and share the
+// handler. On platforms without SIGHUP, call Handle.Reload instead.
+func WithReload(reload func(ctx context.Context) (http.Handler, error)) Option {
+	return func(c *config) {
+		c.reload = reload
+	}
+}
+
+// WithMetrics collects connection and request metrics for the listener
+func WithMetrics(metrics *Metrics) Option {
+	return func(c *config) {
+		c.metrics = metrics
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strings"
 )
 
 //go:generate go run github.com/pointlander/peg -strict -switch -inline parse.peg
@@ -18,6 +19,10 @@ var defaultHost = "127.0.0.1"
 var defaultPort = "3000"
 
 func Parse(input string) (*url.URL, error) {
+	// Control characters aren't valid anywhere in a URL
+	if strings.ContainsFunc(input, isControl) {
+		return nil, fmt.Errorf("%w %q", ErrParsing, input)
+	}
 	parser := &parser{Buffer: input}
 	parser.Init()
 	err := parser.Parse()
@@ -31,6 +36,9 @@ func Parse(input string) (*url.URL, error) {
 		u, err := url.Parse(input)
 		if err != nil {
 			return nil, err
+		} else if u.Host == "" && u.Path == "" {
+			// Nothing to listen on or dial
+			return nil, fmt.Errorf("%w %q", ErrParsing, input)
 		}
 		return u, nil
 	}
@@ -40,7 +48,8 @@ func Parse(input string) (*url.URL, error) {
 
 	// Handle the scheme
 	if parser.url.scheme != "" {
-		u.Scheme = parser.url.scheme
+		// Schemes are case-insensitive, like url.Parse
+		u.Scheme = strings.ToLower(parser.url.scheme)
 	} else if parser.url.port == "443" {
 		u.Scheme = "https"
 	} else {
@@ -51,8 +60,10 @@ func Parse(input string) (*url.URL, error) {
 	hasPort := parser.url.port != ""
 	hasPath := parser.url.path != ""
 
-	// Handle the path if there is one
+	// Handle the path and query if there are any
 	u.Path = parser.url.path
+	// Addresses don't have fragments
+	u.RawQuery, _, _ = strings.Cut(parser.url.query, "#")
 
 	// Handle the port
 	port := defaultPort
@@ -60,11 +71,11 @@ func Parse(input string) (*url.URL, error) {
 		port = "443"
 	}
 
-	if hasHost && u.Scheme == "fd" {
+	if hasHost && (u.Scheme == "fd" || u.Scheme == "mem") {
 		u.Host = parser.url.host
 	}
 
-	if u.Scheme == "unix" || u.Scheme == "fd" {
+	if u.Scheme == "unix" || u.Scheme == "fd" || u.Scheme == "mem" || u.Scheme == "stdio" {
 		return u, nil
 	}
 
@@ -82,11 +93,16 @@ func Parse(input string) (*url.URL, error) {
 	return u, nil
 }
 
+func isControl(r rune) bool {
+	return r < ' ' || r == 0x7f
+}
+
 // Used in the parser
 type uri struct {
 	port   string
 	scheme string
 	host   string
 	path   string
+	query  string
 	uri    string
 }
@@ -1,6 +1,6 @@
 package socket
 
-// Code generated by /var/folders/3p/215s80gx7rx2qs2g9v5r601c0000gp/T/go-build1211496923/b001/exe/peg -strict -switch -inline parse.peg DO NOT EDIT.
+// Code generated by /root/.cache/go-build/a5/a5c7329a1e5d7de136cc5e92a89986ef45f4015880ecb23625cab061e315e4c6-d/peg -strict -switch -inline parse.peg DO NOT EDIT.
 
 import (
 	"fmt"
@@ -22,6 +22,7 @@ const (
 	ruleURI
 	ruleScheme
 	ruleFdScheme
+	ruleMemScheme
 	ruleAnySchema
 	ruleHost
 	ruleIPPort
@@ -36,6 +37,7 @@ const (
 	rulePath
 	ruleRelPath
 	ruleAbsPath
+	ruleQuery
 	ruleBrackets
 	ruleEnd
 	rulePegText
@@ -49,6 +51,8 @@ const (
 	ruleAction7
 	ruleAction8
 	ruleAction9
+	ruleAction10
+	ruleAction11
 )
 
 var rul3s = [...]string{
@@ -57,6 +61,7 @@ var rul3s = [...]string{
 	"URI",
 	"Scheme",
 	"FdScheme",
+	"MemScheme",
 	"AnySchema",
 	"Host",
 	"IPPort",
@@ -71,6 +76,7 @@ var rul3s = [...]string{
 	"Path",
 	"RelPath",
 	"AbsPath",
+	"Query",
 	"Brackets",
 	"End",
 	"PegText",
@@ -84,6 +90,8 @@ var rul3s = [...]string{
 	"Action7",
 	"Action8",
 	"Action9",
+	"Action10",
+	"Action11",
 }
 
 type token32 struct {
@@ -200,7 +208,7 @@ type parser struct {
 
 	Buffer string
 	buffer []rune
-	rules  [32]func() bool
+	rules  [36]func() bool
 	parse  func(rule ...int) error
 	reset  func()
 	Pretty bool
@@ -313,11 +321,12 @@ func (p *parser) Execute() {
 
 		case ruleAction2:
 
-			p.url.scheme = text[:len(text)-1]
+			p.url.scheme = "mem"
+			p.url.host = text[4:]
 
 		case ruleAction3:
 
-			p.url.host = text
+			p.url.scheme = text[:len(text)-1]
 
 		case ruleAction4:
 
@@ -325,15 +334,15 @@ func (p *parser) Execute() {
 
 		case ruleAction5:
 
-			p.url.port = text
+			p.url.host = text
 
 		case ruleAction6:
 
-			p.url.scheme = "unix"
+			p.url.port = text
 
 		case ruleAction7:
 
-			p.url.path = text
+			p.url.scheme = "unix"
 
 		case ruleAction8:
 
@@ -341,6 +350,14 @@ func (p *parser) Execute() {
 
 		case ruleAction9:
 
+			p.url.path = text
+
+		case ruleAction10:
+
+			p.url.query = text
+
+		case ruleAction11:
+
 			p.url.host = "[::]"
 
 		}
@@ -435,7 +452,7 @@ func (p *parser) Init(options ...func(*parser) error) error {
 
 	_rules = [...]func() bool{
 		nil,
-		/* 0 URL <- <(URI / OnlyPath / Scheme / Host / (OnlyPort End))> */
+		/* 0 URL <- <((URI / OnlyPath / Scheme / Host / OnlyPort) Query? End)> */
 		func() bool {
 			position0, tokenIndex0 := position, tokenIndex
 			{
@@ -457,19 +474,39 @@ func (p *parser) Init(options ...func(*parser) error) error {
 								goto l3
 							}
 							position++
-							if !_rules[ruleHost]() {
+							{
+								position8, tokenIndex8 := position, tokenIndex
+								if buffer[position] != rune('?') {
+									goto l8
+								}
+								position++
+								goto l3
+							l8:
+								position, tokenIndex = position8, tokenIndex8
+							}
+							if !matchDot() {
 								goto l3
 							}
+						l6:
 							{
-								position6, tokenIndex6 := position, tokenIndex
-								if !_rules[rulePath]() {
-									goto l6
+								position7, tokenIndex7 := position, tokenIndex
+								{
+									position9, tokenIndex9 := position, tokenIndex
+									if buffer[position] != rune('?') {
+										goto l9
+									}
+									position++
+									goto l7
+								l9:
+									position, tokenIndex = position9, tokenIndex9
+								}
+								if !matchDot() {
+									goto l7
 								}
-								goto l7
-							l6:
-								position, tokenIndex = position6, tokenIndex6
+								goto l6
+							l7:
+								position, tokenIndex = position7, tokenIndex7
 							}
-						l7:
 							add(rulePegText, position5)
 						}
 						{
@@ -481,66 +518,179 @@ func (p *parser) Init(options ...func(*parser) error) error {
 				l3:
 					position, tokenIndex = position2, tokenIndex2
 					{
-						position10 := position
+						position12 := position
 						if !_rules[rulePath]() {
-							goto l9
+							goto l11
 						}
 						{
-							add(ruleAction6, position)
+							add(ruleAction7, position)
 						}
-						add(ruleOnlyPath, position10)
+						add(ruleOnlyPath, position12)
 					}
 					goto l2
-				l9:
+				l11:
 					position, tokenIndex = position2, tokenIndex2
 					if !_rules[ruleScheme]() {
-						goto l12
+						goto l14
 					}
 					goto l2
-				l12:
+				l14:
 					position, tokenIndex = position2, tokenIndex2
-					if !_rules[ruleHost]() {
-						goto l13
+					{
+						position16 := position
+						{
+							position17, tokenIndex17 := position, tokenIndex
+							{
+								position19 := position
+								{
+									position20 := position
+									if !_rules[ruleIPV4]() {
+										goto l18
+									}
+									add(ruleIP, position20)
+								}
+								if buffer[position] != rune(':') {
+									goto l18
+								}
+								position++
+								if !_rules[rulePort]() {
+									goto l18
+								}
+								add(ruleIPPort, position19)
+							}
+							goto l17
+						l18:
+							position, tokenIndex = position17, tokenIndex17
+							{
+								position22 := position
+								if !_rules[ruleHostName]() {
+									goto l21
+								}
+								if buffer[position] != rune(':') {
+									goto l21
+								}
+								position++
+								if !_rules[rulePort]() {
+									goto l21
+								}
+								add(ruleHostNamePort, position22)
+							}
+							goto l17
+						l21:
+							position, tokenIndex = position17, tokenIndex17
+							{
+								position24 := position
+								if !_rules[ruleBrackets]() {
+									goto l23
+								}
+								if buffer[position] != rune(':') {
+									goto l23
+								}
+								position++
+								if !_rules[rulePort]() {
+									goto l23
+								}
+								add(ruleBracketsPort, position24)
+							}
+							goto l17
+						l23:
+							position, tokenIndex = position17, tokenIndex17
+							{
+								switch buffer[position] {
+								case '.', '/':
+									if !_rules[rulePath]() {
+										goto l15
+									}
+								case '[':
+									if !_rules[ruleBrackets]() {
+										goto l15
+									}
+								case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+									if !_rules[ruleIPV4]() {
+										goto l15
+									}
+								default:
+									if !_rules[ruleHostName]() {
+										goto l15
+									}
+								}
+							}
+
+						}
+					l17:
+						add(ruleHost, position16)
 					}
 					goto l2
-				l13:
+				l15:
 					position, tokenIndex = position2, tokenIndex2
 					{
-						position14 := position
+						position26 := position
 						{
-							position15, tokenIndex15 := position, tokenIndex
+							position27, tokenIndex27 := position, tokenIndex
 							if buffer[position] != rune(':') {
-								goto l16
+								goto l28
 							}
 							position++
 							if !_rules[rulePort]() {
-								goto l16
+								goto l28
 							}
-							goto l15
-						l16:
-							position, tokenIndex = position15, tokenIndex15
+							goto l27
+						l28:
+							position, tokenIndex = position27, tokenIndex27
 							if !_rules[rulePort]() {
 								goto l0
 							}
 						}
-					l15:
-						add(ruleOnlyPort, position14)
+					l27:
+						add(ruleOnlyPort, position26)
 					}
+				}
+			l2:
+				{
+					position29, tokenIndex29 := position, tokenIndex
 					{
-						position17 := position
+						position31 := position
+						if buffer[position] != rune('?') {
+							goto l29
+						}
+						position++
 						{
-							position18, tokenIndex18 := position, tokenIndex
-							if !matchDot() {
-								goto l18
+							position32 := position
+						l33:
+							{
+								position34, tokenIndex34 := position, tokenIndex
+								if !matchDot() {
+									goto l34
+								}
+								goto l33
+							l34:
+								position, tokenIndex = position34, tokenIndex34
 							}
-							goto l0
-						l18:
-							position, tokenIndex = position18, tokenIndex18
+							add(rulePegText, position32)
 						}
-						add(ruleEnd, position17)
+						{
+							add(ruleAction10, position)
+						}
+						add(ruleQuery, position31)
 					}
+					goto l30
+				l29:
+					position, tokenIndex = position29, tokenIndex29
+				}
+			l30:
+				{
+					position36 := position
+					{
+						position37, tokenIndex37 := position, tokenIndex
+						if !matchDot() {
+							goto l37
+						}
+						goto l0
+					l37:
+						position, tokenIndex = position37, tokenIndex37
+					}
+					add(ruleEnd, position36)
 				}
-			l2:
 				add(ruleURL, position1)
 			}
 			return true
@@ -548,581 +698,631 @@ func (p *parser) Init(options ...func(*parser) error) error {
 			position, tokenIndex = position0, tokenIndex0
 			return false
 		},
-		/* 1 URI <- <(<(Scheme ('/' '/') Host Path?)> Action0)> */
+		/* 1 URI <- <(<(Scheme ('/' '/') (!'?' .)+)> Action0)> */
 		nil,
-		/* 2 Scheme <- <(FdScheme / AnySchema)> */
+		/* 2 Scheme <- <(FdScheme / MemScheme / AnySchema)> */
 		func() bool {
-			position20, tokenIndex20 := position, tokenIndex
+			position39, tokenIndex39 := position, tokenIndex
 			{
-				position21 := position
+				position40 := position
 				{
-					position22, tokenIndex22 := position, tokenIndex
+					position41, tokenIndex41 := position, tokenIndex
 					{
-						position24 := position
+						position43 := position
 						{
-							position25 := position
+							position44 := position
 							if buffer[position] != rune('f') {
-								goto l23
+								goto l42
 							}
 							position++
 							if buffer[position] != rune('d') {
-								goto l23
+								goto l42
 							}
 							position++
 							if buffer[position] != rune(':') {
-								goto l23
+								goto l42
 							}
 							position++
 							if c := buffer[position]; c < rune('0') || c > rune('9') {
-								goto l23
+								goto l42
 							}
 							position++
-						l26:
+						l45:
 							{
-								position27, tokenIndex27 := position, tokenIndex
+								position46, tokenIndex46 := position, tokenIndex
 								if c := buffer[position]; c < rune('0') || c > rune('9') {
-									goto l27
+									goto l46
 								}
 								position++
-								goto l26
-							l27:
-								position, tokenIndex = position27, tokenIndex27
+								goto l45
+							l46:
+								position, tokenIndex = position46, tokenIndex46
 							}
-							add(rulePegText, position25)
+							add(rulePegText, position44)
 						}
 						{
 							add(ruleAction1, position)
 						}
-						add(ruleFdScheme, position24)
+						add(ruleFdScheme, position43)
 					}
-					goto l22
-				l23:
-					position, tokenIndex = position22, tokenIndex22
+					goto l41
+				l42:
+					position, tokenIndex = position41, tokenIndex41
 					{
-						position29 := position
+						position49 := position
 						{
-							position30 := position
+							position50 := position
+							if buffer[position] != rune('m') {
+								goto l48
+							}
+							position++
+							if buffer[position] != rune('e') {
+								goto l48
+							}
+							position++
+							if buffer[position] != rune('m') {
+								goto l48
+							}
+							position++
+							if buffer[position] != rune(':') {
+								goto l48
+							}
+							position++
+							{
+								switch buffer[position] {
+								case '-':
+									if buffer[position] != rune('-') {
+										goto l48
+									}
+									position++
+								case '_':
+									if buffer[position] != rune('_') {
+										goto l48
+									}
+									position++
+								case '.':
+									if buffer[position] != rune('.') {
+										goto l48
+									}
+									position++
+								case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+									if c := buffer[position]; c < rune('0') || c > rune('9') {
+										goto l48
+									}
+									position++
+								case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z':
+									if c := buffer[position]; c < rune('A') || c > rune('Z') {
+										goto l48
+									}
+									position++
+								default:
+									if c := buffer[position]; c < rune('a') || c > rune('z') {
+										goto l48
+									}
+									position++
+								}
+							}
+
+						l51:
 							{
-								position31, tokenIndex31 := position, tokenIndex
+								position52, tokenIndex52 := position, tokenIndex
+								{
+									switch buffer[position] {
+									case '-':
+										if buffer[position] != rune('-') {
+											goto l52
+										}
+										position++
+									case '_':
+										if buffer[position] != rune('_') {
+											goto l52
+										}
+										position++
+									case '.':
+										if buffer[position] != rune('.') {
+											goto l52
+										}
+										position++
+									case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+										if c := buffer[position]; c < rune('0') || c > rune('9') {
+											goto l52
+										}
+										position++
+									case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z':
+										if c := buffer[position]; c < rune('A') || c > rune('Z') {
+											goto l52
+										}
+										position++
+									default:
+										if c := buffer[position]; c < rune('a') || c > rune('z') {
+											goto l52
+										}
+										position++
+									}
+								}
+
+								goto l51
+							l52:
+								position, tokenIndex = position52, tokenIndex52
+							}
+							add(rulePegText, position50)
+						}
+						{
+							add(ruleAction2, position)
+						}
+						add(ruleMemScheme, position49)
+					}
+					goto l41
+				l48:
+					position, tokenIndex = position41, tokenIndex41
+					{
+						position56 := position
+						{
+							position57 := position
+							{
+								position58, tokenIndex58 := position, tokenIndex
 								if c := buffer[position]; c < rune('a') || c > rune('z') {
-									goto l32
+									goto l59
 								}
 								position++
-								goto l31
-							l32:
-								position, tokenIndex = position31, tokenIndex31
+								goto l58
+							l59:
+								position, tokenIndex = position58, tokenIndex58
 								if c := buffer[position]; c < rune('A') || c > rune('Z') {
-									goto l20
+									goto l39
 								}
 								position++
 							}
-						l31:
-						l33:
+						l58:
+						l60:
 							{
-								position34, tokenIndex34 := position, tokenIndex
+								position61, tokenIndex61 := position, tokenIndex
 								{
 									switch buffer[position] {
 									case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 										if c := buffer[position]; c < rune('0') || c > rune('9') {
-											goto l34
+											goto l61
 										}
 										position++
 									case '+':
 										if buffer[position] != rune('+') {
-											goto l34
+											goto l61
 										}
 										position++
 									case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z':
 										if c := buffer[position]; c < rune('A') || c > rune('Z') {
-											goto l34
+											goto l61
 										}
 										position++
 									default:
 										if c := buffer[position]; c < rune('a') || c > rune('z') {
-											goto l34
+											goto l61
 										}
 										position++
 									}
 								}
 
-								goto l33
-							l34:
-								position, tokenIndex = position34, tokenIndex34
+								goto l60
+							l61:
+								position, tokenIndex = position61, tokenIndex61
 							}
 							if buffer[position] != rune(':') {
-								goto l20
+								goto l39
 							}
 							position++
-							add(rulePegText, position30)
+							add(rulePegText, position57)
 						}
 						{
-							add(ruleAction2, position)
-						}
-						add(ruleAnySchema, position29)
-					}
-				}
-			l22:
-				add(ruleScheme, position21)
-			}
-			return true
-		l20:
-			position, tokenIndex = position20, tokenIndex20
-			return false
-		},
-		/* 3 FdScheme <- <(<('f' 'd' ':' [0-9]+)> Action1)> */
-		nil,
-		/* 4 AnySchema <- <(<(([a-z] / [A-Z]) ((&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') [0-9]) | (&('+') '+') | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z') [A-Z]) | (&('a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') [a-z]))* ':')> Action2)> */
-		nil,
-		/* 5 Host <- <(IPPort / HostNamePort / BracketsPort / ((&('.' | '/') Path) | (&('[') Brackets) | (&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') IPV4) | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z' | 'a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') HostName)))> */
-		func() bool {
-			position39, tokenIndex39 := position, tokenIndex
-			{
-				position40 := position
-				{
-					position41, tokenIndex41 := position, tokenIndex
-					{
-						position43 := position
-						{
-							position44 := position
-							if !_rules[ruleIPV4]() {
-								goto l42
-							}
-							add(ruleIP, position44)
-						}
-						if buffer[position] != rune(':') {
-							goto l42
-						}
-						position++
-						if !_rules[rulePort]() {
-							goto l42
-						}
-						add(ruleIPPort, position43)
-					}
-					goto l41
-				l42:
-					position, tokenIndex = position41, tokenIndex41
-					{
-						position46 := position
-						if !_rules[ruleHostName]() {
-							goto l45
-						}
-						if buffer[position] != rune(':') {
-							goto l45
-						}
-						position++
-						if !_rules[rulePort]() {
-							goto l45
-						}
-						add(ruleHostNamePort, position46)
-					}
-					goto l41
-				l45:
-					position, tokenIndex = position41, tokenIndex41
-					{
-						position48 := position
-						if !_rules[ruleBrackets]() {
-							goto l47
-						}
-						if buffer[position] != rune(':') {
-							goto l47
-						}
-						position++
-						if !_rules[rulePort]() {
-							goto l47
-						}
-						add(ruleBracketsPort, position48)
-					}
-					goto l41
-				l47:
-					position, tokenIndex = position41, tokenIndex41
-					{
-						switch buffer[position] {
-						case '.', '/':
-							if !_rules[rulePath]() {
-								goto l39
-							}
-						case '[':
-							if !_rules[ruleBrackets]() {
-								goto l39
-							}
-						case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-							if !_rules[ruleIPV4]() {
-								goto l39
-							}
-						default:
-							if !_rules[ruleHostName]() {
-								goto l39
-							}
+							add(ruleAction3, position)
 						}
+						add(ruleAnySchema, position56)
 					}
-
 				}
 			l41:
-				add(ruleHost, position40)
+				add(ruleScheme, position40)
 			}
 			return true
 		l39:
 			position, tokenIndex = position39, tokenIndex39
 			return false
 		},
-		/* 6 IPPort <- <(IP ':' Port)> */
+		/* 3 FdScheme <- <(<('f' 'd' ':' [0-9]+)> Action1)> */
+		nil,
+		/* 4 MemScheme <- <(<('m' 'e' 'm' ':' ((&('-') '-') | (&('_') '_') | (&('.') '.') | (&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') [0-9]) | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z') [A-Z]) | (&('a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') [a-z]))+)> Action2)> */
+		nil,
+		/* 5 AnySchema <- <(<(([a-z] / [A-Z]) ((&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') [0-9]) | (&('+') '+') | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z') [A-Z]) | (&('a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') [a-z]))* ':')> Action3)> */
 		nil,
-		/* 7 HostNamePort <- <(HostName ':' Port)> */
+		/* 6 Host <- <(IPPort / HostNamePort / BracketsPort / ((&('.' | '/') Path) | (&('[') Brackets) | (&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') IPV4) | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z' | 'a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') HostName)))> */
 		nil,
-		/* 8 BracketsPort <- <(Brackets ':' Port)> */
+		/* 7 IPPort <- <(IP ':' Port)> */
 		nil,
-		/* 9 IP <- <IPV4> */
+		/* 8 HostNamePort <- <(HostName ':' Port)> */
 		nil,
-		/* 10 IPV4 <- <(<([0-9]+ '.' [0-9]+ '.' [0-9]+ '.' [0-9]+)> Action3)> */
+		/* 9 BracketsPort <- <(Brackets ':' Port)> */
+		nil,
+		/* 10 IP <- <IPV4> */
+		nil,
+		/* 11 IPV4 <- <(<([0-9]+ '.' [0-9]+ '.' [0-9]+ '.' [0-9]+)> Action4)> */
 		func() bool {
-			position54, tokenIndex54 := position, tokenIndex
+			position72, tokenIndex72 := position, tokenIndex
 			{
-				position55 := position
+				position73 := position
 				{
-					position56 := position
+					position74 := position
 					if c := buffer[position]; c < rune('0') || c > rune('9') {
-						goto l54
+						goto l72
 					}
 					position++
-				l57:
+				l75:
 					{
-						position58, tokenIndex58 := position, tokenIndex
+						position76, tokenIndex76 := position, tokenIndex
 						if c := buffer[position]; c < rune('0') || c > rune('9') {
-							goto l58
+							goto l76
 						}
 						position++
-						goto l57
-					l58:
-						position, tokenIndex = position58, tokenIndex58
+						goto l75
+					l76:
+						position, tokenIndex = position76, tokenIndex76
 					}
 					if buffer[position] != rune('.') {
-						goto l54
+						goto l72
 					}
 					position++
 					if c := buffer[position]; c < rune('0') || c > rune('9') {
-						goto l54
+						goto l72
 					}
 					position++
-				l59:
+				l77:
 					{
-						position60, tokenIndex60 := position, tokenIndex
+						position78, tokenIndex78 := position, tokenIndex
 						if c := buffer[position]; c < rune('0') || c > rune('9') {
-							goto l60
+							goto l78
 						}
 						position++
-						goto l59
-					l60:
-						position, tokenIndex = position60, tokenIndex60
+						goto l77
+					l78:
+						position, tokenIndex = position78, tokenIndex78
 					}
 					if buffer[position] != rune('.') {
-						goto l54
+						goto l72
 					}
 					position++
 					if c := buffer[position]; c < rune('0') || c > rune('9') {
-						goto l54
+						goto l72
 					}
 					position++
-				l61:
+				l79:
 					{
-						position62, tokenIndex62 := position, tokenIndex
+						position80, tokenIndex80 := position, tokenIndex
 						if c := buffer[position]; c < rune('0') || c > rune('9') {
-							goto l62
+							goto l80
 						}
 						position++
-						goto l61
-					l62:
-						position, tokenIndex = position62, tokenIndex62
+						goto l79
+					l80:
+						position, tokenIndex = position80, tokenIndex80
 					}
 					if buffer[position] != rune('.') {
-						goto l54
+						goto l72
 					}
 					position++
 					if c := buffer[position]; c < rune('0') || c > rune('9') {
-						goto l54
+						goto l72
 					}
 					position++
-				l63:
+				l81:
 					{
-						position64, tokenIndex64 := position, tokenIndex
+						position82, tokenIndex82 := position, tokenIndex
 						if c := buffer[position]; c < rune('0') || c > rune('9') {
-							goto l64
+							goto l82
 						}
 						position++
-						goto l63
-					l64:
-						position, tokenIndex = position64, tokenIndex64
+						goto l81
+					l82:
+						position, tokenIndex = position82, tokenIndex82
 					}
-					add(rulePegText, position56)
+					add(rulePegText, position74)
 				}
 				{
-					add(ruleAction3, position)
+					add(ruleAction4, position)
 				}
-				add(ruleIPV4, position55)
+				add(ruleIPV4, position73)
 			}
 			return true
-		l54:
-			position, tokenIndex = position54, tokenIndex54
+		l72:
+			position, tokenIndex = position72, tokenIndex72
 			return false
 		},
-		/* 11 HostName <- <(<(([a-z] / [A-Z]) ((&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') [0-9]) | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z') [A-Z]) | (&('a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') [a-z]))*)> Action4)> */
+		/* 12 HostName <- <(<(([a-z] / [A-Z]) ((&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') [0-9]) | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z') [A-Z]) | (&('a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') [a-z]))*)> Action5)> */
 		func() bool {
-			position66, tokenIndex66 := position, tokenIndex
+			position84, tokenIndex84 := position, tokenIndex
 			{
-				position67 := position
+				position85 := position
 				{
-					position68 := position
+					position86 := position
 					{
-						position69, tokenIndex69 := position, tokenIndex
+						position87, tokenIndex87 := position, tokenIndex
 						if c := buffer[position]; c < rune('a') || c > rune('z') {
-							goto l70
+							goto l88
 						}
 						position++
-						goto l69
-					l70:
-						position, tokenIndex = position69, tokenIndex69
+						goto l87
+					l88:
+						position, tokenIndex = position87, tokenIndex87
 						if c := buffer[position]; c < rune('A') || c > rune('Z') {
-							goto l66
+							goto l84
 						}
 						position++
 					}
-				l69:
-				l71:
+				l87:
+				l89:
 					{
-						position72, tokenIndex72 := position, tokenIndex
+						position90, tokenIndex90 := position, tokenIndex
 						{
 							switch buffer[position] {
 							case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 								if c := buffer[position]; c < rune('0') || c > rune('9') {
-									goto l72
+									goto l90
 								}
 								position++
 							case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z':
 								if c := buffer[position]; c < rune('A') || c > rune('Z') {
-									goto l72
+									goto l90
 								}
 								position++
 							default:
 								if c := buffer[position]; c < rune('a') || c > rune('z') {
-									goto l72
+									goto l90
 								}
 								position++
 							}
 						}
 
-						goto l71
-					l72:
-						position, tokenIndex = position72, tokenIndex72
+						goto l89
+					l90:
+						position, tokenIndex = position90, tokenIndex90
 					}
-					add(rulePegText, position68)
+					add(rulePegText, position86)
 				}
 				{
-					add(ruleAction4, position)
+					add(ruleAction5, position)
 				}
-				add(ruleHostName, position67)
+				add(ruleHostName, position85)
 			}
 			return true
-		l66:
-			position, tokenIndex = position66, tokenIndex66
+		l84:
+			position, tokenIndex = position84, tokenIndex84
 			return false
 		},
-		/* 12 OnlyPort <- <((':' Port) / Port)> */
+		/* 13 OnlyPort <- <((':' Port) / Port)> */
 		nil,
-		/* 13 Port <- <(<('0' / ([1-9] [0-9]*))> Action5)> */
+		/* 14 Port <- <(<('0' / ([1-9] [0-9]*))> Action6)> */
 		func() bool {
-			position76, tokenIndex76 := position, tokenIndex
+			position94, tokenIndex94 := position, tokenIndex
 			{
-				position77 := position
+				position95 := position
 				{
-					position78 := position
+					position96 := position
 					{
-						position79, tokenIndex79 := position, tokenIndex
+						position97, tokenIndex97 := position, tokenIndex
 						if buffer[position] != rune('0') {
-							goto l80
+							goto l98
 						}
 						position++
-						goto l79
-					l80:
-						position, tokenIndex = position79, tokenIndex79
+						goto l97
+					l98:
+						position, tokenIndex = position97, tokenIndex97
 						if c := buffer[position]; c < rune('1') || c > rune('9') {
-							goto l76
+							goto l94
 						}
 						position++
-					l81:
+					l99:
 						{
-							position82, tokenIndex82 := position, tokenIndex
+							position100, tokenIndex100 := position, tokenIndex
 							if c := buffer[position]; c < rune('0') || c > rune('9') {
-								goto l82
+								goto l100
 							}
 							position++
-							goto l81
-						l82:
-							position, tokenIndex = position82, tokenIndex82
+							goto l99
+						l100:
+							position, tokenIndex = position100, tokenIndex100
 						}
 					}
-				l79:
-					add(rulePegText, position78)
+				l97:
+					add(rulePegText, position96)
 				}
 				{
-					add(ruleAction5, position)
+					add(ruleAction6, position)
 				}
-				add(rulePort, position77)
+				add(rulePort, position95)
 			}
 			return true
-		l76:
-			position, tokenIndex = position76, tokenIndex76
+		l94:
+			position, tokenIndex = position94, tokenIndex94
 			return false
 		},
-		/* 14 OnlyPath <- <(Path Action6)> */
+		/* 15 OnlyPath <- <(Path Action7)> */
 		nil,
-		/* 15 Path <- <(RelPath / AbsPath)> */
+		/* 16 Path <- <(RelPath / AbsPath)> */
 		func() bool {
-			position85, tokenIndex85 := position, tokenIndex
+			position103, tokenIndex103 := position, tokenIndex
 			{
-				position86 := position
+				position104 := position
 				{
-					position87, tokenIndex87 := position, tokenIndex
+					position105, tokenIndex105 := position, tokenIndex
 					{
-						position89 := position
+						position107 := position
 						{
-							position90 := position
+							position108 := position
 							if buffer[position] != rune('.') {
-								goto l88
+								goto l106
 							}
 							position++
 							if buffer[position] != rune('/') {
-								goto l88
+								goto l106
 							}
 							position++
-						l91:
+						l109:
 							{
-								position92, tokenIndex92 := position, tokenIndex
+								position110, tokenIndex110 := position, tokenIndex
+								{
+									position111, tokenIndex111 := position, tokenIndex
+									if buffer[position] != rune('?') {
+										goto l111
+									}
+									position++
+									goto l110
+								l111:
+									position, tokenIndex = position111, tokenIndex111
+								}
 								if !matchDot() {
-									goto l92
+									goto l110
 								}
-								goto l91
-							l92:
-								position, tokenIndex = position92, tokenIndex92
+								goto l109
+							l110:
+								position, tokenIndex = position110, tokenIndex110
 							}
-							add(rulePegText, position90)
+							add(rulePegText, position108)
 						}
 						{
-							add(ruleAction7, position)
+							add(ruleAction8, position)
 						}
-						add(ruleRelPath, position89)
+						add(ruleRelPath, position107)
 					}
-					goto l87
-				l88:
-					position, tokenIndex = position87, tokenIndex87
+					goto l105
+				l106:
+					position, tokenIndex = position105, tokenIndex105
 					{
-						position94 := position
+						position113 := position
 						{
-							position95 := position
+							position114 := position
 							if buffer[position] != rune('/') {
-								goto l85
+								goto l103
 							}
 							position++
-						l96:
+						l115:
 							{
-								position97, tokenIndex97 := position, tokenIndex
+								position116, tokenIndex116 := position, tokenIndex
+								{
+									position117, tokenIndex117 := position, tokenIndex
+									if buffer[position] != rune('?') {
+										goto l117
+									}
+									position++
+									goto l116
+								l117:
+									position, tokenIndex = position117, tokenIndex117
+								}
 								if !matchDot() {
-									goto l97
+									goto l116
 								}
-								goto l96
-							l97:
-								position, tokenIndex = position97, tokenIndex97
+								goto l115
+							l116:
+								position, tokenIndex = position116, tokenIndex116
 							}
-							add(rulePegText, position95)
+							add(rulePegText, position114)
 						}
 						{
-							add(ruleAction8, position)
+							add(ruleAction9, position)
 						}
-						add(ruleAbsPath, position94)
+						add(ruleAbsPath, position113)
 					}
 				}
-			l87:
-				add(rulePath, position86)
+			l105:
+				add(rulePath, position104)
 			}
 			return true
-		l85:
-			position, tokenIndex = position85, tokenIndex85
+		l103:
+			position, tokenIndex = position103, tokenIndex103
 			return false
 		},
-		/* 16 RelPath <- <(<('.' '/' .*)> Action7)> */
+		/* 17 RelPath <- <(<('.' '/' (!'?' .)*)> Action8)> */
 		nil,
-		/* 17 AbsPath <- <(<('/' .*)> Action8)> */
+		/* 18 AbsPath <- <(<('/' (!'?' .)*)> Action9)> */
 		nil,
-		/* 18 Brackets <- <('[' ':' ':' ']' Action9)> */
+		/* 19 Query <- <('?' <.*> Action10)> */
+		nil,
+		/* 20 Brackets <- <('[' ':' ':' ']' Action11)> */
 		func() bool {
-			position101, tokenIndex101 := position, tokenIndex
+			position122, tokenIndex122 := position, tokenIndex
 			{
-				position102 := position
+				position123 := position
 				if buffer[position] != rune('[') {
-					goto l101
+					goto l122
 				}
 				position++
 				if buffer[position] != rune(':') {
-					goto l101
+					goto l122
 				}
 				position++
 				if buffer[position] != rune(':') {
-					goto l101
+					goto l122
 				}
 				position++
 				if buffer[position] != rune(']') {
-					goto l101
+					goto l122
 				}
 				position++
 				{
-					add(ruleAction9, position)
+					add(ruleAction11, position)
 				}
-				add(ruleBrackets, position102)
+				add(ruleBrackets, position123)
 			}
 			return true
-		l101:
-			position, tokenIndex = position101, tokenIndex101
+		l122:
+			position, tokenIndex = position122, tokenIndex122
 			return false
 		},
-		/* 19 End <- <!.> */
+		/* 21 End <- <!.> */
 		nil,
 		nil,
-		/* 22 Action0 <- <{
+		/* 24 Action0 <- <{
 		  p.url.uri = text
 		}> */
 		nil,
-		/* 23 Action1 <- <{
+		/* 25 Action1 <- <{
 		  p.url.scheme = "fd"
 		  p.url.host = text[3:]
 		}> */
 		nil,
-		/* 24 Action2 <- <{
+		/* 26 Action2 <- <{
+		  p.url.scheme = "mem"
+		  p.url.host = text[4:]
+		}> */
+		nil,
+		/* 27 Action3 <- <{
 		  p.url.scheme = text[:len(text)-1]
 		}> */
 		nil,
-		/* 25 Action3 <- <{
+		/* 28 Action4 <- <{
 		  p.url.host = text
 		}> */
 		nil,
-		/* 26 Action4 <- <{
+		/* 29 Action5 <- <{
 		  p.url.host = text
 		}> */
 		nil,
-		/* 27 Action5 <- <{
+		/* 30 Action6 <- <{
 		  p.url.port = text
 		}> */
 		nil,
-		/* 28 Action6 <- <{
+		/* 31 Action7 <- <{
 		  p.url.scheme = "unix"
 		}> */
 		nil,
-		/* 29 Action7 <- <{
+		/* 32 Action8 <- <{
 		  p.url.path = text
 		}> */
 		nil,
-		/* 30 Action8 <- <{
+		/* 33 Action9 <- <{
 		  p.url.path = text
 		}> */
 		nil,
-		/* 31 Action9 <- <{
+		/* 34 Action10 <- <{
+		  p.url.query = text
+		}> */
+		nil,
+		/* 35 Action11 <- <{
 		  p.url.host = "[::]"
 		}> */
 		nil,
@@ -107,3 +107,55 @@ func TestParseFd3(t *testing.T) {
 func TestParseFd20(t *testing.T) {
 	equal(t, "fd:20", "fd://20")
 }
+
+func TestParseMem(t *testing.T) {
+	equal(t, "mem:api", "mem://api")
+	equal(t, "mem:api-v2.test", "mem://api-v2.test")
+	equal(t, "mem:api?max_conns=1", "mem://api?max_conns=1")
+}
+
+func TestParseStdio(t *testing.T) {
+	equal(t, "stdio:", "stdio:")
+}
+
+func TestParseQuery(t *testing.T) {
+	equal(t, ":5000?proxy=v2", "http://127.0.0.1:5000?proxy=v2")
+}
+
+func TestParseUnixQuery(t *testing.T) {
+	equal(t, "/tmp.sock?proxy=v1", "unix:///tmp.sock?proxy=v1")
+}
+
+func TestParseFdQuery(t *testing.T) {
+	equal(t, "fd:3?proxy=v2", "fd://3?proxy=v2")
+}
+
+// parseSeeds are the inputs from the tests above
+var parseSeeds = []string{
+	"5000", ":5000", "0", "0.0.0.0", "127.0.0.1", "127.0.0.1:5000", "localhost",
+	"otherhost", "/tmp.sock", "/whatever/tmp.sock", "./whatever/tmp.sock",
+	"https:", "https://localhost:8000/a/b/c", "80.ab", "http://127.0.0.1:49341",
+	"[::]:50516", "[::]:443", "[::]:80", "unix://./some/path", "unix:///some/path",
+	"fd:3", "fd:20", "mem:api", "mem:api-v2.test", "mem:api?max_conns=1",
+	"stdio:", ":5000?proxy=v2", "/tmp.sock?proxy=v1", "fd:3?proxy=v2",
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range parseSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		u, err := socket.Parse(input)
+		if err != nil {
+			return
+		}
+		// Parsed URLs parse again to the same value
+		again, err := socket.Parse(u.String())
+		if err != nil {
+			t.Fatalf("%q parsed as %q, which doesn't parse: %v", input, u, err)
+		}
+		if again.String() != u.String() {
+			t.Fatalf("%q parsed as %q, then as %q", input, u, again)
+		}
+	})
+}
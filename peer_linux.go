@@ -0,0 +1,28 @@
+package socket
+
+import (
+	"net"
+	"syscall"
+)
+
+// peerCred returns the process and user on the other side of a unix domain
+// socket
+func peerCred(conn net.Conn) (pid, uid int32, ok bool) {
+	uc, isUnix := conn.(*net.UnixConn)
+	if !isUnix {
+		return 0, 0, false
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return 0, 0, false
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil || credErr != nil {
+		return 0, 0, false
+	}
+	return cred.Pid, int32(cred.Uid), true
+}
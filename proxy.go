@@ -0,0 +1,395 @@
+package socket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrProxyHeader is returned when a PROXY protocol header is missing or
+// malformed
+var ErrProxyHeader = errors.New("socket: invalid proxy protocol header")
+
+// proxySignature prefixes every PROXY protocol v2 header
+var proxySignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// defaultProxyTimeout is how long to wait for a PROXY protocol header
+const defaultProxyTimeout = 10 * time.Second
+
+// ProxyListener wraps a listener and reads PROXY protocol v1 and v2 headers
+// sent by load balancers like HAProxy or an AWS NLB. RemoteAddr on accepted
+// connections reports the real client address.
+type ProxyListener struct {
+	net.Listener
+
+	// Timeout to read the header. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	// Trusted upstream networks. Headers are only read from peers within these
+	// networks, connections from other peers are passed through untouched. An
+	// empty list trusts every peer.
+	Trusted []*net.IPNet
+
+	once   sync.Once
+	conns  chan net.Conn
+	closed chan struct{}
+	err    error
+}
+
+var _ net.Listener = (*ProxyListener)(nil)
+
+// Accept waits for the next connection with a valid header. Headers are read
+// in the background so slow peers don't block other connections.
+func (l *ProxyListener) Accept() (net.Conn, error) {
+	l.once.Do(l.start)
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, l.err
+	}
+}
+
+func (l *ProxyListener) start() {
+	l.conns = make(chan net.Conn)
+	l.closed = make(chan struct{})
+	go l.accept()
+}
+
+func (l *ProxyListener) accept() {
+	for {
+		conn, err := acceptBackoff(l.Listener)
+		if err != nil {
+			l.err = err
+			close(l.closed)
+			return
+		}
+		go func() {
+			pc, err := l.handshake(conn)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			select {
+			case l.conns <- pc:
+			case <-l.closed:
+				conn.Close()
+			}
+		}()
+	}
+}
+
+// handshake reads the header from trusted peers
+func (l *ProxyListener) handshake(conn net.Conn) (net.Conn, error) {
+	if !l.trusts(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	timeout := l.Timeout
+	if timeout <= 0 {
+		timeout = defaultProxyTimeout
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	src, dst, err := readProxyHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	return &proxyConn{conn, reader, src, dst}, nil
+}
+
+// Unwrap returns the underlying listener
+func (l *ProxyListener) Unwrap() net.Listener {
+	return l.Listener
+}
+
+func (l *ProxyListener) trusts(addr net.Addr) bool {
+	if len(l.Trusted) == 0 {
+		return true
+	}
+	ip := addrIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range l.Trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyConn reports the addresses from the PROXY protocol header
+type proxyConn struct {
+	net.Conn
+	reader *bufio.Reader
+	src    net.Addr
+	dst    net.Addr
+}
+
+func (c *proxyConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	if c.src == nil {
+		return c.Conn.RemoteAddr()
+	}
+	return c.src
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	if c.dst == nil {
+		return c.Conn.LocalAddr()
+	}
+	return c.dst
+}
+
+// readProxyHeader reads a v1 or v2 header. Nil addresses mean the header
+// didn't carry any, for example health checks from the load balancer itself.
+func readProxyHeader(r *bufio.Reader) (src, dst net.Addr, err error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrProxyHeader, err)
+	}
+	switch first[0] {
+	case 'P':
+		return readProxyV1(r)
+	case proxySignature[0]:
+		return readProxyV2(r)
+	default:
+		return nil, nil, fmt.Errorf("%w: missing signature", ErrProxyHeader)
+	}
+}
+
+// readProxyV1 reads the human-readable header
+// (e.g. "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n")
+func readProxyV1(r *bufio.Reader) (src, dst net.Addr, err error) {
+	// v1 headers are at most 107 bytes
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrProxyHeader, err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, fmt.Errorf("%w: v1 header too long", ErrProxyHeader)
+	}
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if fields[0] != "PROXY" {
+		return nil, nil, fmt.Errorf("%w: missing signature", ErrProxyHeader)
+	}
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, fmt.Errorf("%w: malformed v1 header %q", ErrProxyHeader, line)
+	}
+	srcIP, dstIP := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	if srcIP == nil || dstIP == nil {
+		return nil, nil, fmt.Errorf("%w: invalid v1 address %q", ErrProxyHeader, line)
+	}
+	srcPort, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid v1 port %q", ErrProxyHeader, line)
+	}
+	dstPort, err := strconv.ParseUint(fields[5], 10, 16)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid v1 port %q", ErrProxyHeader, line)
+	}
+	src = &net.TCPAddr{IP: srcIP, Port: int(srcPort)}
+	dst = &net.TCPAddr{IP: dstIP, Port: int(dstPort)}
+	return src, dst, nil
+}
+
+// readProxyV2 reads the binary header
+func readProxyV2(r *bufio.Reader) (src, dst net.Addr, err error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrProxyHeader, err)
+	}
+	if !bytes.Equal(header[:12], proxySignature) {
+		return nil, nil, fmt.Errorf("%w: missing signature", ErrProxyHeader)
+	}
+	if header[12]>>4 != 2 {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", ErrProxyHeader, header[12]>>4)
+	}
+	body := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrProxyHeader, err)
+	}
+	switch header[12] & 0x0f {
+	case 0x0: // LOCAL, the connection was made by the proxy itself
+		return nil, nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported command %d", ErrProxyHeader, header[12]&0x0f)
+	}
+	switch header[13] {
+	case 0x11, 0x12: // TCP or UDP over IPv4
+		if len(body) < 12 {
+			return nil, nil, fmt.Errorf("%w: short ipv4 addresses", ErrProxyHeader)
+		}
+		src = &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}
+		dst = &net.TCPAddr{IP: net.IP(body[4:8]), Port: int(binary.BigEndian.Uint16(body[10:12]))}
+		return src, dst, nil
+	case 0x21, 0x22: // TCP or UDP over IPv6
+		if len(body) < 36 {
+			return nil, nil, fmt.Errorf("%w: short ipv6 addresses", ErrProxyHeader)
+		}
+		src = &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}
+		dst = &net.TCPAddr{IP: net.IP(body[16:32]), Port: int(binary.BigEndian.Uint16(body[34:36]))}
+		return src, dst, nil
+	default: // UNSPEC, unix sockets or unknown families keep the real addresses
+		return nil, nil, nil
+	}
+}
+
+// WriteProxyHeader writes a PROXY protocol header of the given version (1 or
+// 2) describing a connection from src to dst. Non-TCP addresses are written as
+// UNKNOWN in v1 and UNSPEC in v2.
+func WriteProxyHeader(w io.Writer, version int, src, dst net.Addr) error {
+	srcTCP, srcOk := src.(*net.TCPAddr)
+	dstTCP, dstOk := dst.(*net.TCPAddr)
+	known := srcOk && dstOk
+	ipv4 := known && srcTCP.IP.To4() != nil && dstTCP.IP.To4() != nil
+	switch version {
+	case 1:
+		if !known {
+			_, err := io.WriteString(w, "PROXY UNKNOWN\r\n")
+			return err
+		}
+		family := "TCP6"
+		if ipv4 {
+			family = "TCP4"
+		}
+		_, err := fmt.Fprintf(w, "PROXY %s %s %s %d %d\r\n", family, srcTCP.IP, dstTCP.IP, srcTCP.Port, dstTCP.Port)
+		return err
+	case 2:
+		header := append([]byte{}, proxySignature...)
+		var body []byte
+		switch {
+		case !known:
+			header = append(header, 0x21, 0x00)
+		case ipv4:
+			header = append(header, 0x21, 0x11)
+			body = append(body, srcTCP.IP.To4()...)
+			body = append(body, dstTCP.IP.To4()...)
+			body = binary.BigEndian.AppendUint16(body, uint16(srcTCP.Port))
+			body = binary.BigEndian.AppendUint16(body, uint16(dstTCP.Port))
+		default:
+			header = append(header, 0x21, 0x21)
+			body = append(body, srcTCP.IP.To16()...)
+			body = append(body, dstTCP.IP.To16()...)
+			body = binary.BigEndian.AppendUint16(body, uint16(srcTCP.Port))
+			body = binary.BigEndian.AppendUint16(body, uint16(dstTCP.Port))
+		}
+		header = binary.BigEndian.AppendUint16(header, uint16(len(body)))
+		_, err := w.Write(append(header, body...))
+		return err
+	default:
+		return fmt.Errorf("socket: unsupported proxy protocol version %d", version)
+	}
+}
+
+// proxyVersion parses the ?proxy= query value. Zero means disabled.
+func proxyVersion(query url.Values) (int, error) {
+	switch value := query.Get("proxy"); value {
+	case "":
+		return 0, nil
+	case "1", "v1":
+		return 1, nil
+	case "2", "v2":
+		return 2, nil
+	default:
+		return 0, fmt.Errorf("socket: invalid proxy version %q", value)
+	}
+}
+
+// proxyListener configures a ProxyListener from the address query
+// (e.g. ?proxy=v2&proxy_timeout=5s&proxy_trusted=10.0.0.0/8). The listener
+// accepts both header versions regardless of the version given.
+func proxyListener(query url.Values) (*ProxyListener, error) {
+	version, err := proxyVersion(query)
+	if err != nil {
+		return nil, err
+	} else if version == 0 {
+		return nil, nil
+	}
+	pl := new(ProxyListener)
+	if value := query.Get("proxy_timeout"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("socket: invalid proxy_timeout %q: %w", value, err)
+		}
+		pl.Timeout = timeout
+	}
+	if value := query.Get("proxy_trusted"); value != "" {
+		trusted, err := parseCIDRs(value)
+		if err != nil {
+			return nil, err
+		}
+		pl.Trusted = trusted
+	}
+	return pl, nil
+}
+
+// parseCIDRs parses a comma-separated list of networks. Plain IPs are treated
+// as single-host networks.
+func parseCIDRs(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("socket: invalid network %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("socket: invalid network %q: %w", value, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// addrIP returns the IP of a TCP or UDP address
+func addrIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,114 @@
+package socket_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+func serveRemoteAddr(t testing.TB, addr string) net.Listener {
+	t.Helper()
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	ln, err := socket.Listen(addr)
+	is.NoErr(err)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.Serve(ctx, ln, handler) })
+	t.Cleanup(func() {
+		cancel()
+		eg.Wait()
+	})
+	return ln
+}
+
+func TestProxyV1(t *testing.T) {
+	is := is.New(t)
+	ln := serveRemoteAddr(t, ":0?proxy=v1")
+	conn, err := socket.Dial(context.Background(), ln.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	src := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4321}
+	is.NoErr(socket.WriteProxyHeader(conn, 1, src, ln.Addr()))
+	_, err = io.WriteString(conn, "GET / HTTP/1.0\r\n\r\n")
+	is.NoErr(err)
+	res, err := io.ReadAll(conn)
+	is.NoErr(err)
+	is.True(strings.HasSuffix(string(res), "203.0.113.7:4321"))
+}
+
+func TestProxyV2IPv6(t *testing.T) {
+	is := is.New(t)
+	ln := serveRemoteAddr(t, ":0?proxy=v2")
+	conn, err := socket.Dial(context.Background(), ln.Addr().String())
+	is.NoErr(err)
+	defer conn.Close()
+	src := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 4321}
+	dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 80}
+	is.NoErr(socket.WriteProxyHeader(conn, 2, src, dst))
+	_, err = io.WriteString(conn, "GET / HTTP/1.0\r\n\r\n")
+	is.NoErr(err)
+	res, err := io.ReadAll(conn)
+	is.NoErr(err)
+	is.True(strings.HasSuffix(string(res), "[2001:db8::1]:4321"))
+}
+
+func TestProxyTransport(t *testing.T) {
+	is := is.New(t)
+	ln := serveRemoteAddr(t, ":0?proxy=v2")
+	transport, err := socket.Transport(ln.Addr().String() + "?proxy=v2")
+	is.NoErr(err)
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   time.Second,
+	}
+	res, err := client.Get("http://" + ln.Addr().String())
+	is.NoErr(err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	is.NoErr(err)
+	// The header carries the client's own address
+	host, _, err := net.SplitHostPort(string(body))
+	is.NoErr(err)
+	is.Equal(host, "127.0.0.1")
+}
+
+func TestProxyMissingHeader(t *testing.T) {
+	is := is.New(t)
+	ln := serveRemoteAddr(t, ":0?proxy=v2&proxy_timeout=100ms")
+	client := &http.Client{Timeout: time.Second}
+	_, err := client.Get("http://" + ln.Addr().String())
+	is.True(err != nil) // connection should have been dropped
+}
+
+func TestProxyUntrusted(t *testing.T) {
+	is := is.New(t)
+	ln := serveRemoteAddr(t, ":0?proxy=v2&proxy_trusted=10.0.0.0/8")
+	client := &http.Client{Timeout: time.Second}
+	res, err := client.Get("http://" + ln.Addr().String())
+	is.NoErr(err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	is.NoErr(err)
+	host, _, err := net.SplitHostPort(string(body))
+	is.NoErr(err)
+	is.Equal(host, "127.0.0.1")
+}
+
+func TestProxyInvalidVersion(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen(":0?proxy=v3")
+	is.True(err != nil)
+	is.Equal(ln, nil)
+	is.Equal(err.Error(), `socket: invalid proxy version "v3"`)
+}
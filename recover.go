@@ -0,0 +1,53 @@
+package socket
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicReporter is called with the value and stack trace of a handler that
+// panicked. Use it to forward panics to an error tracker.
+type PanicReporter func(r *http.Request, value any, stack []byte)
+
+// WithRecover recovers from panicking handlers with a 500 Internal Server
+// Error and reports the panic. When report is nil, panics are logged to the
+// logger from WithLogger. Panics are counted by WithMetrics.
+func WithRecover(report PanicReporter) Option {
+	return func(c *config) {
+		c.recover = true
+		c.reportPanic = report
+	}
+}
+
+// recoverPanic recovers from a panic in a handler. Deferred by the handler.
+func (s *httpServer) recoverPanic(w *responseWriter, r *http.Request) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	// ErrAbortHandler aborts the response on purpose, let net/http handle it
+	if value == http.ErrAbortHandler {
+		panic(value)
+	}
+	stack := debug.Stack()
+	if s.metrics != nil {
+		s.metrics.panics.Add(1)
+	}
+	if s.config.reportPanic != nil {
+		s.config.reportPanic(r, value, stack)
+	} else {
+		s.config.log().LogAttrs(r.Context(), slog.LevelError, "handler panicked",
+			slog.Any("panic", value),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.RequestURI()),
+			slog.String("stack", string(stack)),
+		)
+	}
+	// Once the response has started, the best we can do is abort it so the
+	// client doesn't mistake it for a complete response
+	if w.status != 0 || w.hijacked {
+		panic(http.ErrAbortHandler)
+	}
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
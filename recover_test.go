@@ -0,0 +1,123 @@
+package socket_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestServeRecover(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	label := socket.Format(ln)
+	var reported any
+	var stack []byte
+	report := func(r *http.Request, value any, s []byte) {
+		reported = value
+		stack = s
+	}
+	metrics := new(socket.Metrics)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oh no")
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.Serve(ctx, ln, handler, socket.WithRecover(report), socket.WithMetrics(metrics))
+	})
+	res, err := http.Get("http://" + ln.Addr().String())
+	is.NoErr(err)
+	res.Body.Close()
+	is.Equal(res.StatusCode, 500)
+	is.Equal(reported, "oh no")
+	is.True(strings.Contains(string(stack), "recover_test.go"))
+	cancel()
+	is.NoErr(eg.Wait())
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	is.True(strings.Contains(rec.Body.String(), `socket_panics_total{listener="`+label+`"} 1`))
+}
+
+func TestServeRecoverLogs(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	buf := new(syncBuffer)
+	logger := slog.New(slog.NewTextHandler(buf, nil))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oh no")
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.Serve(ctx, ln, handler, socket.WithRecover(nil), socket.WithLogger(logger))
+	})
+	res, err := http.Get("http://" + ln.Addr().String() + "/a")
+	is.NoErr(err)
+	res.Body.Close()
+	is.Equal(res.StatusCode, 500)
+	cancel()
+	is.NoErr(eg.Wait())
+	is.True(strings.Contains(buf.String(), `level=ERROR msg="handler panicked" panic="oh no" method=GET path=/a stack=`))
+}
+
+func TestServeRecoverStartedResponse(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		io.WriteString(w, "partial")
+		w.(http.Flusher).Flush()
+		panic("oh no")
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.Serve(ctx, ln, handler, socket.WithRecover(func(*http.Request, any, []byte) {}))
+	})
+	res, err := http.Get("http://" + ln.Addr().String())
+	is.NoErr(err)
+	is.Equal(res.StatusCode, 200)
+	// The response should be aborted rather than look complete
+	_, err = io.ReadAll(res.Body)
+	is.True(err != nil)
+	res.Body.Close()
+	cancel()
+	is.NoErr(eg.Wait())
+}
+
+func TestServeRecoverAbortHandler(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	reported := false
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.Serve(ctx, ln, handler, socket.WithRecover(func(*http.Request, any, []byte) {
+			reported = true
+		}))
+	})
+	_, err = http.Get("http://" + ln.Addr().String())
+	is.True(err != nil) // the connection should have been aborted
+	is.True(!reported)
+	cancel()
+	is.NoErr(eg.Wait())
+}
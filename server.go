@@ -0,0 +1,131 @@
+package socket
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Server is implemented by servers that can be gracefully shutdown, like
+// *http.Server. Servers with a Close method have it called to close any
+// remaining connections after a forced shutdown.
+type Server interface {
+	Serve(net.Listener) error
+	Shutdown(context.Context) error
+}
+
+var _ Server = (*http.Server)(nil)
+
+// ServeServer serves the server on the listener. When the context is canceled,
+// the server will be gracefully shutdown. Another interrupt or the grace
+// period forces the shutdown.
+func ServeServer(ctx context.Context, listener net.Listener, server Server, options ...Option) error {
+	config := newConfig(options)
+	// Shutdown the server if serving fails, so we don't leak the server
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Count accepts, wrapping connections for servers that can't count them
+	var metrics *listenerMetrics
+	if config.metrics != nil {
+		metrics = config.metrics.listener(Format(listener))
+		onReject(listener, metrics.reject)
+		listener = &metricsListener{
+			Listener:  listener,
+			metrics:   metrics,
+			wrapConns: !tracksConns(server),
+		}
+	}
+	// Make the server shutdownable
+	shutdownCh := shutdown(ctx, config, server, func(ctx context.Context) error {
+		if metrics != nil {
+			defer func(start time.Time) {
+				metrics.shutdown.Store(int64(time.Since(start)))
+			}(time.Now())
+		}
+		if err := server.Shutdown(ctx); err != nil {
+			// Close the remaining connections
+			if closer, ok := server.(interface{ Close() error }); ok && isForced(err) {
+				closer.Close()
+			}
+			return err
+		}
+		return nil
+	})
+	config.log().Info("listening", "addr", Format(listener))
+	// Serve requests. Errors after the shutdown began are expected, otherwise
+	// serving stopped on its own and the server needs to be shutdown.
+	if err := server.Serve(listener); ctx.Err() == nil || (err != nil && !isClosed(err)) {
+		cancel()
+		<-shutdownCh
+		// Listeners like stdio stop accepting once they're done
+		if errors.Is(err, errDone) {
+			return nil
+		}
+		return err
+	}
+	// Handle any errors that occurred while shutting down
+	if err := <-shutdownCh; err != nil {
+		if !isForced(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// tracksConns is true for servers whose connections shouldn't be wrapped to
+// measure them. Serve and ServeConn measure their own connections. Wrapping
+// the connections of an *http.Server would hide *tls.Conn from it, so only its
+// accepts are counted.
+func tracksConns(server Server) bool {
+	switch server.(type) {
+	case *httpServer, *connServer, *http.Server:
+		return true
+	default:
+		return false
+	}
+}
+
+// isClosed is true when serving stopped because of a shutdown
+func isClosed(err error) bool {
+	return errors.Is(err, http.ErrServerClosed) || errors.Is(err, net.ErrClosed)
+}
+
+// isForced is true when the error came from forcing the shutdown
+func isForced(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// GracefulStopper is implemented by servers that stop without a context, like
+// *grpc.Server
+type GracefulStopper interface {
+	Serve(net.Listener) error
+	GracefulStop()
+	Stop()
+}
+
+// Graceful adapts a GracefulStopper into a Server. Shutdown calls GracefulStop
+// and falls back to Stop when the shutdown is forced.
+func Graceful(server GracefulStopper) Server {
+	return &gracefulServer{server}
+}
+
+type gracefulServer struct {
+	GracefulStopper
+}
+
+func (s *gracefulServer) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
+}
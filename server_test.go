@@ -0,0 +1,122 @@
+package socket_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"golang.org/x/sync/errgroup"
+)
+
+// stopServer mimics a server with GracefulStop and Stop, like *grpc.Server
+type stopServer struct {
+	mu       sync.Mutex
+	listener net.Listener
+	stopped  chan struct{}
+	graceful bool
+	blocking bool
+}
+
+func (s *stopServer) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	s.listener = ln
+	s.mu.Unlock()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			// Like *grpc.Server, return nil after stopping
+			return nil
+		}
+		conn.Close()
+	}
+}
+
+func (s *stopServer) GracefulStop() {
+	s.mu.Lock()
+	s.graceful = true
+	s.listener.Close()
+	s.mu.Unlock()
+	if s.blocking {
+		<-s.stopped
+	}
+}
+
+func (s *stopServer) Stop() {
+	close(s.stopped)
+}
+
+func TestServeServer(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener, err := socket.Listen(":0")
+	is.NoErr(err)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(205)
+		}),
+	}
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.ServeServer(ctx, listener, server) })
+	res, err := http.Get("http://" + listener.Addr().String())
+	is.NoErr(err)
+	is.Equal(res.StatusCode, 205)
+	cancel()
+	is.NoErr(eg.Wait())
+}
+
+func TestServeServerError(t *testing.T) {
+	is := is.New(t)
+	listener, err := socket.Listen(":0")
+	is.NoErr(err)
+	// Closing the listener from underneath the server is a fatal error
+	is.NoErr(listener.Close())
+	err = socket.ServeServer(context.Background(), listener, &http.Server{})
+	is.True(errors.Is(err, net.ErrClosed))
+}
+
+func TestServeGraceful(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener, err := socket.Listen(":0")
+	is.NoErr(err)
+	server := &stopServer{stopped: make(chan struct{})}
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.ServeServer(ctx, listener, socket.Graceful(server)) })
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	is.NoErr(err)
+	conn.Close()
+	cancel()
+	is.NoErr(eg.Wait())
+	is.True(server.graceful)
+}
+
+func TestServeGracefulForced(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener, err := socket.Listen(":0")
+	is.NoErr(err)
+	server := &stopServer{stopped: make(chan struct{}), blocking: true}
+	eg := new(errgroup.Group)
+	eg.Go(func() error {
+		return socket.ServeServer(ctx, listener, socket.Graceful(server), socket.WithGracePeriod(10*time.Millisecond))
+	})
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	is.NoErr(err)
+	conn.Close()
+	cancel()
+	eg.Wait()
+	select {
+	case <-server.stopped:
+	default:
+		is.Fail() // should have been stopped
+	}
+}
@@ -2,16 +2,23 @@ package socket
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"time"
 )
 
-// Listen creates a new listener based on the addr
+// Listen creates a new listener based on the addr. Query parameters on the
+// addr wrap the listener with extra behavior (e.g. ?proxy=v2).
 func Listen(addr string) (net.Listener, error) {
+	return new(ListenConfig).Listen(context.Background(), addr)
+}
+
+// Listen creates a new listener based on the addr with the config applied
+func (c *ListenConfig) Listen(ctx context.Context, addr string) (net.Listener, error) {
 	// If the addr is empty, listen on a random port
 	if addr == "" {
 		addr = ":0"
@@ -22,101 +29,186 @@ func Listen(addr string) (net.Listener, error) {
 	if err != nil {
 		return nil, err
 	}
+	return c.listenURL(ctx, url)
+}
 
-	// Handle unix, tcp, and fd schemes
+func (c *ListenConfig) listenURL(ctx context.Context, url *url.URL) (net.Listener, error) {
+	// Parse the query before binding so we don't need to unbind on errors
+	query := url.Query()
+	config, err := c.withQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := proxyListener(query)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := limitListener(query)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := rateListener(query)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := filterListener(query)
+	if err != nil {
+		return nil, err
+	}
+	// Untrusted peers could forge a header claiming an allowed address
+	if filter != nil && proxy != nil && len(proxy.Trusted) == 0 {
+		return nil, fmt.Errorf("socket: allow and deny need proxy_trusted when combined with proxy")
+	}
+
+	ln, err := config.listen(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	ln, err = config.bound(ln)
+	if err != nil {
+		return nil, err
+	}
+
+	// Slow down and cap accepts before they use up file descriptors
+	if rate != nil {
+		rate.Listener = ln
+		ln = rate
+	}
+	if limit != nil {
+		limit.Listener = ln
+		ln = limit
+	}
+
+	// Read PROXY protocol headers from load balancers
+	if proxy != nil {
+		proxy.Listener = ln
+		ln = proxy
+	}
+
+	// Filter peers after the PROXY header has revealed their real address
+	if filter != nil {
+		filter.Listener = ln
+		ln = filter
+	}
+
+	return ln, nil
+}
+
+// listen binds to the address without any wrappers
+func (c *ListenConfig) listen(ctx context.Context, url *url.URL) (net.Listener, error) {
+	lc := &net.ListenConfig{Control: c.control}
+	lc.KeepAlive, lc.KeepAliveConfig = c.keepAlive(0, net.KeepAliveConfig{})
+	// Handle unix, tcp, fd, stdio and mem schemes
 	switch url.Scheme {
 	case "unix":
+		path := unixPath(url)
 		// Unix domain socket paths can't be more than 103 characters long
-		if len(addr) > 103 {
-			return nil, fmt.Errorf("socket: unix path too long %q", addr)
+		if len(path) > 103 {
+			return nil, fmt.Errorf("socket: unix path too long %q", path)
 		}
-		addr, err := net.ResolveUnixAddr("unix", addr)
+		addr, err := net.ResolveUnixAddr("unix", path)
 		if err != nil {
 			return nil, err
 		}
-		ln, err := net.ListenUnix("unix", addr)
-		if err != nil {
-			return nil, err
-		}
-		return ln, nil
+		return lc.Listen(ctx, "unix", addr.String())
 
 	case "fd":
 		return listenFd(url)
 
+	case "stdio":
+		return listenStdio()
+
+	case "mem":
+		return listenMem(url.Host)
+
 		// Otherwise, bind to a TCP port
 	default:
 		addr, err := net.ResolveTCPAddr("tcp", url.Host)
 		if err != nil {
 			return nil, err
 		}
-		ln, err := net.ListenTCP("tcp", addr)
-		if err != nil {
-			return nil, err
-		}
-		return ln, nil
+		return lc.Listen(ctx, "tcp", addr.String())
 	}
 }
 
-// Serve the handler at address. // When the context is canceled, the server
-// will be gracefully shutdown.
-func Serve(ctx context.Context, listener net.Listener, handler http.Handler) error {
-	// Create the HTTP server
-	server := &http.Server{
-		Addr:    listener.Addr().String(),
-		Handler: handler,
-	}
-	// Make the server shutdownable
-	shutdownCh := shutdown(ctx, server)
-	// Serve requests
-	if err := server.Serve(listener); err != nil {
-		if !errors.Is(err, http.ErrServerClosed) {
-			return err
-		}
-	}
-	// Handle any errors that occurred while shutting down
-	if err := <-shutdownCh; err != nil {
-		if !errors.Is(err, context.Canceled) {
-			return err
-		}
-	}
-	return nil
+// unixPath returns the path of a unix domain socket url. Relative paths like
+// unix://./some/path parse with "." as the host.
+func unixPath(url *url.URL) string {
+	return url.Host + url.Path
+}
+
+// Serve the handler at address. When the context is canceled, the server
+// will be gracefully shutdown. Serve also waits for hijacked connections, like
+// WebSockets, to close and closes them if the shutdown is forced.
+func Serve(ctx context.Context, listener net.Listener, handler http.Handler, options ...Option) error {
+	return Start(ctx, listener, handler, options...).Wait()
 }
 
 // ListenAndServe is a convenience function that combines Listen and Serve.
 // When the context is canceled, the server will be gracefully shutdown.
-func ListenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+func ListenAndServe(ctx context.Context, addr string, handler http.Handler, options ...Option) error {
 	ln, err := Listen(addr)
 	if err != nil {
 		return err
 	}
-	return Serve(ctx, ln, handler)
+	return Serve(ctx, ln, handler, options...)
 }
 
-// Shutdown the server when the context is canceled
-func shutdown(ctx context.Context, server *http.Server) <-chan error {
+// Shutdown the server when the context is canceled. The server drains first,
+// if it can. The context passed to stop is canceled on another interrupt or
+// after the grace period, signaling that the shutdown should be forced.
+func shutdown(ctx context.Context, config *config, server any, stop func(context.Context) error) <-chan error {
 	shutdown := make(chan error, 1)
 	go func() {
 		<-ctx.Done()
+		log := config.log()
+		log.Info("shutting down")
+		start := time.Now()
 		// Wait for one more interrupt to force an immediate shutdown, otherwise
 		// take as much time as needed to finish ongoing requests
-		forceCtx := trap(context.Background(), os.Interrupt)
-		if err := server.Shutdown(forceCtx); err != nil {
+		forceCtx, cancel := trap(context.Background(), os.Interrupt)
+		defer cancel()
+		// The grace period starts once the drain is over
+		if d, ok := server.(drainer); ok {
+			d.drain(forceCtx)
+		}
+		if config.gracePeriod > 0 {
+			forceCtx, cancel = context.WithTimeout(forceCtx, config.gracePeriod)
+			defer cancel()
+		}
+		if err := stop(forceCtx); err != nil {
+			if isForced(err) {
+				log.Warn("forced shutdown", "duration", time.Since(start))
+			} else {
+				log.Error("shutdown failed", "err", err)
+			}
 			shutdown <- err
+		} else {
+			log.Info("shutdown complete", "duration", time.Since(start))
 		}
 		close(shutdown)
 	}()
 	return shutdown
 }
 
+// drainer is implemented by servers that keep serving for a while before
+// shutting down
+type drainer interface {
+	drain(ctx context.Context)
+}
+
 // Trap cancels the context based on a signal
-func trap(ctx context.Context, signals ...os.Signal) context.Context {
+func trap(ctx context.Context, signals ...os.Signal) (context.Context, context.CancelFunc) {
 	ret, cancel := context.WithCancel(ctx)
 	ch := make(chan os.Signal, len(signals))
+	signal.Notify(ch, signals...)
 	go func() {
-		<-ch
+		select {
+		case <-ch:
+		case <-ret.Done():
+		}
 		signal.Stop(ch)
 		cancel()
 	}()
-	signal.Notify(ch, signals...)
-	return ret
+	return ret, cancel
 }
@@ -0,0 +1,241 @@
+package sockettest
+
+import (
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Listener injects faults into the connections it accepts. Each connection gets
+// its own seed from the listener's random source.
+type Listener struct {
+	net.Listener
+	Faults
+
+	once sync.Once
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+var _ net.Listener = (*Listener)(nil)
+
+func (l *Listener) init() {
+	l.rand = newRand(l.Seed)
+}
+
+// Accept the next connection that isn't refused
+func (l *Listener) Accept() (net.Conn, error) {
+	l.once.Do(l.init)
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		l.mu.Lock()
+		refuse := l.rand.Float64() < l.RefuseRate
+		seed := l.rand.Uint64()
+		l.mu.Unlock()
+		if refuse {
+			reset(conn)
+			continue
+		}
+		faults := l.Faults
+		faults.Seed = seed
+		return &Conn{Conn: conn, Faults: faults}, nil
+	}
+}
+
+// Conn injects faults into reads and writes. Wrap a connection from Dial to
+// inject faults on the client side.
+type Conn struct {
+	net.Conn
+	Faults
+
+	once         sync.Once
+	mu           sync.Mutex
+	rand         *rand.Rand
+	bytes        int64
+	readDeadline time.Time
+	changed      chan struct{} // closed when the read deadline changes
+	closeOnce    sync.Once
+	closed       chan struct{}
+}
+
+var _ net.Conn = (*Conn)(nil)
+
+func (c *Conn) init() {
+	c.rand = newRand(c.Seed)
+	c.changed = make(chan struct{})
+	c.closed = make(chan struct{})
+}
+
+// Read with latency, bandwidth limits, stalls and resets
+func (c *Conn) Read(p []byte) (int, error) {
+	c.once.Do(c.init)
+	if c.chance(c.StallRate) {
+		return 0, c.stall()
+	}
+	c.delay()
+	limit := c.remaining()
+	if limit == 0 {
+		return 0, c.reset("read")
+	} else if limit > 0 && int64(len(p)) > limit {
+		p = p[:limit]
+	}
+	n, err := c.Conn.Read(p)
+	c.throttle(n)
+	return n, err
+}
+
+// Write with latency, bandwidth limits, partial writes and resets
+func (c *Conn) Write(p []byte) (int, error) {
+	c.once.Do(c.init)
+	c.delay()
+	limit := c.remaining()
+	if limit == 0 {
+		return 0, c.reset("write")
+	}
+	var short error
+	resetting := false
+	if limit > 0 && int64(len(p)) > limit {
+		p = p[:limit]
+		resetting = true
+	} else if len(p) > 1 && c.chance(c.PartialWriteRate) {
+		p = p[:1+c.intN(len(p)-1)]
+		short = io.ErrShortWrite
+	}
+	n, err := c.Conn.Write(p)
+	c.throttle(n)
+	if err != nil {
+		return n, err
+	} else if resetting {
+		return n, c.reset("write")
+	}
+	return n, short
+}
+
+// SetDeadline also wakes up stalled reads
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.setReadDeadline(t)
+	return c.Conn.SetDeadline(t)
+}
+
+// SetReadDeadline also wakes up stalled reads
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.setReadDeadline(t)
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *Conn) setReadDeadline(t time.Time) {
+	c.once.Do(c.init)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Close the connection, waking up stalled reads
+func (c *Conn) Close() error {
+	c.once.Do(c.init)
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+// chance is true with the probability of the rate
+func (c *Conn) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rand.Float64() < rate
+}
+
+func (c *Conn) intN(n int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rand.IntN(n)
+}
+
+// delay by the latency and jitter
+func (c *Conn) delay() {
+	latency := c.Latency
+	if c.Jitter > 0 {
+		c.mu.Lock()
+		latency += time.Duration(c.rand.Int64N(int64(c.Jitter)))
+		c.mu.Unlock()
+	}
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+}
+
+// throttle counts the bytes and waits long enough to stay within the bandwidth
+func (c *Conn) throttle(n int) {
+	c.mu.Lock()
+	c.bytes += int64(n)
+	c.mu.Unlock()
+	if c.Bandwidth > 0 && n > 0 {
+		time.Sleep(time.Duration(n) * time.Second / time.Duration(c.Bandwidth))
+	}
+}
+
+// remaining returns how many bytes can pass before a reset, or -1 for no limit
+func (c *Conn) remaining() int64 {
+	if c.ResetAfter <= 0 {
+		return -1
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return max(c.ResetAfter-c.bytes, 0)
+}
+
+// stall until the read deadline passes or the connection is closed
+func (c *Conn) stall() error {
+	for {
+		c.mu.Lock()
+		deadline, changed := c.readDeadline, c.changed
+		c.mu.Unlock()
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if !deadline.IsZero() {
+			timer = time.NewTimer(time.Until(deadline))
+			timeout = timer.C
+		}
+		select {
+		case <-timeout:
+			return os.ErrDeadlineExceeded
+		case <-changed:
+		case <-c.closed:
+			return net.ErrClosed
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// reset the connection like a peer that went away
+func (c *Conn) reset(op string) error {
+	reset(c.Conn)
+	return &net.OpError{
+		Op:     op,
+		Net:    c.LocalAddr().Network(),
+		Source: c.LocalAddr(),
+		Addr:   c.RemoteAddr(),
+		Err:    syscall.ECONNRESET,
+	}
+}
+
+// reset closes the connection, sending a RST on TCP
+func reset(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
@@ -0,0 +1,145 @@
+package sockettest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"github.com/matthewmueller/socket/sockettest"
+	"golang.org/x/sync/errgroup"
+)
+
+// writes returns the sizes of partial writes with the seed
+func writes(t testing.TB, seed uint64) []int {
+	t.Helper()
+	is := is.New(t)
+	client, _ := sockettest.Pipe(t)
+	conn := &sockettest.Conn{Conn: client, Faults: sockettest.Faults{Seed: seed, PartialWriteRate: 0.5}}
+	var sizes []int
+	for range 20 {
+		n, err := conn.Write(bytes.Repeat([]byte("x"), 100))
+		if err != nil {
+			is.True(errors.Is(err, io.ErrShortWrite))
+			is.True(n > 0 && n < 100)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes
+}
+
+func TestReproducible(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+	a := writes(t, 42)
+	b := writes(t, 42)
+	is.Equal(a, b)
+	short := 0
+	for _, n := range a {
+		if n < 100 {
+			short++
+		}
+	}
+	is.True(short > 0 && short < len(a)) // expected some partial writes
+}
+
+func TestResetAfter(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+	client, server := sockettest.Pipe(t)
+	conn := &sockettest.Conn{Conn: client, Faults: sockettest.Faults{ResetAfter: 5}}
+	n, err := conn.Write([]byte("hello world"))
+	is.Equal(n, 5)
+	is.True(errors.Is(err, syscall.ECONNRESET))
+	_, err = conn.Read(make([]byte, 1))
+	is.True(errors.Is(err, syscall.ECONNRESET))
+	// The peer sees what was written before the reset
+	data, err := io.ReadAll(server)
+	is.NoErr(err)
+	is.Equal(string(data), "hello")
+}
+
+func TestStall(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+	client, server := sockettest.Pipe(t)
+	conn := &sockettest.Conn{Conn: server, Faults: sockettest.Faults{StallRate: 1}}
+	_, err := client.Write([]byte("x"))
+	is.NoErr(err)
+	// Stalled reads wait for the deadline, even with data ready
+	is.NoErr(conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond)))
+	_, err = conn.Read(make([]byte, 1))
+	is.True(errors.Is(err, os.ErrDeadlineExceeded))
+	// Or until the connection is closed
+	is.NoErr(conn.SetReadDeadline(time.Time{}))
+	time.AfterFunc(20*time.Millisecond, func() { conn.Close() })
+	_, err = conn.Read(make([]byte, 1))
+	is.True(errors.Is(err, net.ErrClosed))
+}
+
+func TestLatencyAndBandwidth(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+	client, server := sockettest.Pipe(t)
+	conn := &sockettest.Conn{Conn: client, Faults: sockettest.Faults{Latency: 20 * time.Millisecond, Bandwidth: 1000}}
+	go io.Copy(io.Discard, server)
+	start := time.Now()
+	_, err := conn.Write(bytes.Repeat([]byte("x"), 100))
+	is.NoErr(err)
+	// 20ms of latency and 100ms to send 100 bytes at 1000 bytes per second
+	is.True(time.Since(start) >= 120*time.Millisecond)
+}
+
+func TestListenerRefuse(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+	ln, err := socket.Listen(":0")
+	is.NoErr(err)
+	faulty := &sockettest.Listener{Listener: ln, Faults: sockettest.Faults{Seed: 1, RefuseRate: 1}}
+	defer faulty.Close()
+	go faulty.Accept()
+	// Depending on timing, the reset arrives while dialing or reading
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err == nil {
+		defer conn.Close()
+		_, err = conn.Read(make([]byte, 1))
+	}
+	is.True(err != nil) // expected the connection to be refused
+}
+
+func TestListenerServe(t *testing.T) {
+	t.Parallel()
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	ln, err := socket.Listen("mem:listener-serve")
+	is.NoErr(err)
+	faulty := &sockettest.Listener{Listener: ln, Faults: sockettest.Faults{
+		Seed:    7,
+		Latency: time.Millisecond,
+		Jitter:  time.Millisecond,
+	}}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	})
+	eg := new(errgroup.Group)
+	eg.Go(func() error { return socket.Serve(ctx, faulty, handler) })
+	transport, err := socket.Transport("mem:listener-serve")
+	is.NoErr(err)
+	client := &http.Client{Transport: transport, Timeout: time.Second}
+	res, err := client.Get("http://api/slow")
+	is.NoErr(err)
+	body, err := io.ReadAll(res.Body)
+	is.NoErr(err)
+	res.Body.Close()
+	is.Equal(string(body), "/slow")
+	cancel()
+	is.NoErr(eg.Wait())
+}
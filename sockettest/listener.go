@@ -0,0 +1,314 @@
+package sockettest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matthewmueller/socket"
+)
+
+// TestListener checks that listening on the address behaves like the built-in
+// schemes. Each subtest listens on the address again, so it should be free
+// once the previous listener closes.
+func TestListener(t *testing.T, addr string) {
+	t.Helper()
+	t.Run("Format", func(t *testing.T) { testFormat(t, addr) })
+	t.Run("Serve", func(t *testing.T) { testServe(t, addr) })
+	t.Run("Dial", func(t *testing.T) { testDial(t, addr) })
+	t.Run("Shutdown", func(t *testing.T) { testShutdown(t, addr) })
+	t.Run("Close", func(t *testing.T) { testClose(t, addr) })
+	t.Run("ConcurrentAccept", func(t *testing.T) { testConcurrentAccept(t, addr) })
+}
+
+// listen on the address, closing the listener when the test ends
+func listen(t *testing.T, addr string) net.Listener {
+	t.Helper()
+	ln, err := socket.Listen(addr)
+	if err != nil {
+		t.Fatalf("sockettest: unable to listen on %q: %v", addr, err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+// testFormat checks that the formatted listener parses back to its address
+func testFormat(t *testing.T, addr string) {
+	ln := listen(t, addr)
+	formatted := socket.Format(ln)
+	url, err := socket.Parse(formatted)
+	if err != nil {
+		t.Fatalf("sockettest: unable to parse the formatted listener %q: %v", formatted, err)
+	}
+	// Formatting is stable
+	again, err := socket.Parse(url.String())
+	if err != nil {
+		t.Fatalf("sockettest: unable to parse %q: %v", url, err)
+	} else if again.String() != url.String() {
+		t.Fatalf("sockettest: %q parsed as %q, then as %q", formatted, url, again)
+	}
+	switch network := ln.Addr().Network(); network {
+	case "tcp":
+		_, port, err := net.SplitHostPort(ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if url.Port() != port {
+			t.Fatalf("sockettest: %q parsed with port %q, expected %q", formatted, url.Port(), port)
+		}
+	case "unix":
+		if url.Scheme != "unix" || url.Path != ln.Addr().String() {
+			t.Fatalf("sockettest: %q parsed as %q, expected the unix path %q", formatted, url, ln.Addr())
+		}
+	default:
+		if url.Scheme != network {
+			t.Fatalf("sockettest: %q parsed with scheme %q, expected %q", formatted, url.Scheme, network)
+		}
+	}
+}
+
+// serve the handler until the test ends, returning a client for the listener
+// and a function that shuts the server down
+func serve(t *testing.T, ln net.Listener, handler http.Handler) (*http.Client, func() error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- socket.Serve(ctx, ln, handler) }()
+	transport, err := socket.Transport(socket.Format(ln))
+	if err != nil {
+		cancel()
+		t.Fatalf("sockettest: unable to create a transport for %q: %v", socket.Format(ln), err)
+	}
+	var once sync.Once
+	var serveErr error
+	stop := func() error {
+		once.Do(func() {
+			cancel()
+			serveErr = <-served
+			transport.CloseIdleConnections()
+		})
+		return serveErr
+	}
+	t.Cleanup(func() { stop() })
+	return &http.Client{Transport: transport, Timeout: 5 * time.Second}, stop
+}
+
+// get the path, returning the body
+func get(client *http.Client, path string) (string, error) {
+	res, err := client.Get("http://sockettest" + path)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	} else if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("sockettest: unexpected status %d", res.StatusCode)
+	}
+	return string(body), nil
+}
+
+// testServe checks that HTTP is served through Serve and reachable with
+// Transport
+func testServe(t *testing.T, addr string) {
+	ln := listen(t, addr)
+	client, stop := serve(t, ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	for _, path := range []string{"/a", "/b"} {
+		body, err := get(client, path)
+		if err != nil {
+			t.Fatalf("sockettest: unable to get %s: %v", path, err)
+		} else if body != path {
+			t.Fatalf("sockettest: expected %q, got %q", path, body)
+		}
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("sockettest: serve returned %v", err)
+	}
+}
+
+// testDial checks that Dial reaches the listener
+func testDial(t *testing.T, addr string) {
+	ln := listen(t, addr)
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			accepted <- err
+			return
+		}
+		defer conn.Close()
+		_, err = io.Copy(conn, conn)
+		accepted <- err
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := socket.Dial(ctx, socket.Format(ln))
+	if err != nil {
+		t.Fatalf("sockettest: unable to dial %q: %v", socket.Format(ln), err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("sockettest: unable to write: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("sockettest: unable to read the echo: %v", err)
+	} else if string(buf) != "ping" {
+		t.Fatalf("sockettest: expected the echo %q, got %q", "ping", buf)
+	}
+	conn.Close()
+	if err := <-accepted; err != nil && !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("sockettest: unable to echo: %v", err)
+	}
+}
+
+// testShutdown checks that in-flight requests finish after the shutdown began
+// and that new connections are refused
+func testShutdown(t *testing.T, addr string) {
+	ln := listen(t, addr)
+	formatted := socket.Format(ln)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	client, stop := serve(t, ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	}))
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		body, err := get(client, "/")
+		results <- result{body, err}
+	}()
+	<-started
+	stopped := make(chan error, 1)
+	go func() { stopped <- stop() }()
+	// Wait for the listener to stop taking new connections
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		conn, err := socket.Dial(ctx, formatted)
+		cancel()
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("sockettest: %q still accepts connections after shutting down", formatted)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	res := <-results
+	if res.err != nil {
+		t.Fatalf("sockettest: in-flight request failed during shutdown: %v", res.err)
+	} else if res.body != "done" {
+		t.Fatalf("sockettest: expected %q, got %q", "done", res.body)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatalf("sockettest: serve returned %v", err)
+	}
+}
+
+// testClose checks that closing stops accepts and cleans up unix socket files
+func testClose(t *testing.T, addr string) {
+	ln := listen(t, addr)
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		accepted <- err
+	}()
+	if err := ln.Close(); err != nil {
+		t.Fatalf("sockettest: unable to close: %v", err)
+	}
+	select {
+	case err := <-accepted:
+		if err == nil {
+			t.Fatal("sockettest: accept succeeded after closing")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sockettest: accept is still blocked after closing")
+	}
+	if ln.Addr().Network() == "unix" {
+		if _, err := os.Stat(ln.Addr().String()); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("sockettest: expected %q to be removed, got %v", ln.Addr(), err)
+		}
+	}
+}
+
+// testConcurrentAccept checks that concurrent accepts each get a connection
+func testConcurrentAccept(t *testing.T, addr string) {
+	const n = 8
+	ln := listen(t, addr)
+	formatted := socket.Format(ln)
+	received := make(chan byte, n)
+	errs := make(chan error, 2*n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := ln.Accept()
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			buf := make([]byte, 1)
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				errs <- err
+				return
+			}
+			received <- buf[0]
+		}()
+	}
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := socket.Dial(ctx, formatted)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+			if _, err := conn.Write([]byte{byte(i)}); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("sockettest: concurrent accept failed: %v", err)
+	}
+	close(received)
+	seen := map[byte]bool{}
+	for b := range received {
+		seen[b] = true
+	}
+	if len(seen) != n {
+		t.Fatalf("sockettest: expected %d distinct connections, got %d", n, len(seen))
+	}
+}
@@ -0,0 +1,27 @@
+package sockettest_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket/sockettest"
+)
+
+func TestListenerTCP(t *testing.T) {
+	sockettest.TestListener(t, ":0")
+}
+
+func TestListenerUnix(t *testing.T) {
+	is := is.New(t)
+	// Keep the path short enough for a unix socket
+	dir, err := os.MkdirTemp("", "sockettest")
+	is.NoErr(err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	sockettest.TestListener(t, filepath.Join(dir, "test.sock"))
+}
+
+func TestListenerMem(t *testing.T) {
+	sockettest.TestListener(t, "mem:suite")
+}
@@ -0,0 +1,41 @@
+package sockettest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/matthewmueller/socket"
+)
+
+// pipes numbers the in-memory listeners behind each pipe
+var pipes atomic.Int64
+
+// Pipe returns both ends of an in-memory connection from the mem: scheme.
+// Unlike net.Pipe, writes are buffered and each end can be half-closed, like a
+// socket. Both ends are closed when the test ends.
+func Pipe(t testing.TB) (client, server net.Conn) {
+	t.Helper()
+	addr := fmt.Sprintf("mem:sockettest-pipe-%d", pipes.Add(1))
+	ln, err := socket.Listen(addr)
+	if err != nil {
+		t.Fatalf("sockettest: unable to listen on %q: %v", addr, err)
+	}
+	defer ln.Close()
+	client, err = socket.Dial(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("sockettest: unable to dial %q: %v", addr, err)
+	}
+	server, err = ln.Accept()
+	if err != nil {
+		client.Close()
+		t.Fatalf("sockettest: unable to accept on %q: %v", addr, err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
@@ -0,0 +1,46 @@
+// Package sockettest helps test servers and clients built on socket. Listener
+// and Conn inject faults like latency, resets and stalls, driven by a seeded
+// random source so runs are reproducible.
+package sockettest
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Faults to inject into connections. Rates are probabilities between 0 and 1.
+// The zero value injects nothing.
+type Faults struct {
+	// Seed for the random source. Runs with the same seed inject the same
+	// faults.
+	Seed uint64
+
+	// Latency added before every read and write
+	Latency time.Duration
+
+	// Jitter adds up to this much more latency at random
+	Jitter time.Duration
+
+	// Bandwidth limits reads and writes to this many bytes per second
+	Bandwidth int
+
+	// ResetAfter resets the connection once this many bytes have been read and
+	// written
+	ResetAfter int64
+
+	// StallRate is how often reads stall until their deadline or the
+	// connection is closed
+	StallRate float64
+
+	// PartialWriteRate is how often writes stop partway through with
+	// io.ErrShortWrite
+	PartialWriteRate float64
+
+	// RefuseRate is how often the listener resets a connection instead of
+	// returning it from Accept
+	RefuseRate float64
+}
+
+func newRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed))
+}
@@ -0,0 +1,204 @@
+package socket
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SocketOptions tune TCP sockets on both ends. Listeners pass them on to the
+// connections they accept. The zero value keeps the system defaults.
+type SocketOptions struct {
+	// KeepAlive configures the keep-alive probes when Enable is true
+	KeepAlive net.KeepAliveConfig
+
+	// NoKeepAlive turns keep-alive probes off
+	NoKeepAlive bool
+
+	// Delay turns TCP_NODELAY off, so small writes are batched
+	Delay bool
+
+	// FastOpen turns on TCP_FASTOPEN. For listeners, it's the length of the
+	// queue of pending fast open requests. Linux only.
+	FastOpen int
+
+	// DeferAccept waits up to the duration for data to arrive before accepting
+	// a connection with TCP_DEFER_ACCEPT. Listeners only. Linux only.
+	DeferAccept time.Duration
+
+	// UserTimeout closes connections when sent data stays unacknowledged for
+	// the duration with TCP_USER_TIMEOUT. Linux only.
+	UserTimeout time.Duration
+
+	// RecvBuffer is the size of the receive buffer (SO_RCVBUF) in bytes
+	RecvBuffer int
+
+	// SendBuffer is the size of the send buffer (SO_SNDBUF) in bytes
+	SendBuffer int
+}
+
+// keepAlive overrides the keep-alive settings of a net.Dialer or
+// net.ListenConfig
+func (o *SocketOptions) keepAlive(period time.Duration, config net.KeepAliveConfig) (time.Duration, net.KeepAliveConfig) {
+	if o.NoKeepAlive {
+		return -1, net.KeepAliveConfig{}
+	} else if o.KeepAlive.Enable {
+		return period, o.KeepAlive
+	}
+	return period, config
+}
+
+// control sets the options on a raw socket before it's bound or connected
+func (o *SocketOptions) control(network string, raw syscall.RawConn, listening bool) error {
+	isTCP := strings.HasPrefix(network, "tcp")
+	var err error
+	ctrlErr := raw.Control(func(fd uintptr) {
+		// set keeps the first error
+		set := func(level, opt, value int, name string) {
+			if err != nil {
+				return
+			} else if opt == 0 {
+				err = fmt.Errorf("socket: %s is not supported on %s", name, runtime.GOOS)
+			} else if err = setsockoptInt(fd, level, opt, value); err != nil {
+				err = fmt.Errorf("socket: unable to set %s: %w", name, err)
+			}
+		}
+		if o.RecvBuffer > 0 {
+			set(solSocket, soRcvbuf, o.RecvBuffer, "rcvbuf")
+		}
+		if o.SendBuffer > 0 {
+			set(solSocket, soSndbuf, o.SendBuffer, "sndbuf")
+		}
+		if !isTCP {
+			return
+		}
+		if o.UserTimeout > 0 {
+			set(ipprotoTCP, tcpUserTimeout, int(o.UserTimeout.Milliseconds()), "user_timeout")
+		}
+		if o.FastOpen > 0 {
+			if listening {
+				set(ipprotoTCP, tcpFastOpen, o.FastOpen, "fastopen")
+			} else {
+				set(ipprotoTCP, tcpFastOpenConnect, 1, "fastopen")
+			}
+		}
+		if o.DeferAccept > 0 && listening {
+			set(ipprotoTCP, tcpDeferAccept, max(int(o.DeferAccept.Seconds()), 1), "defer_accept")
+		}
+	})
+	if ctrlErr != nil {
+		return ctrlErr
+	}
+	return err
+}
+
+// setConn sets the options that Go resets on every connection
+func (o *SocketOptions) setConn(conn net.Conn) error {
+	if tcp, ok := conn.(*net.TCPConn); ok && o.Delay {
+		return tcp.SetNoDelay(false)
+	}
+	return nil
+}
+
+// withQuery returns a copy of the options with the address query applied
+// (e.g. ?keepalive=30s&nodelay=0&rcvbuf=65536)
+func (o SocketOptions) withQuery(query url.Values) (*SocketOptions, error) {
+	if value := query.Get("keepalive"); value != "" {
+		switch value {
+		case "off", "false", "0":
+			o.NoKeepAlive = true
+		default:
+			idle, err := time.ParseDuration(value)
+			if err != nil || idle < 0 {
+				return nil, fmt.Errorf("socket: invalid keepalive %q", value)
+			}
+			o.KeepAlive.Enable = true
+			o.KeepAlive.Idle = idle
+		}
+	}
+	if value := query.Get("keepalive_interval"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("socket: invalid keepalive_interval %q", value)
+		}
+		o.KeepAlive.Enable = true
+		o.KeepAlive.Interval = interval
+	}
+	if value := query.Get("keepalive_count"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil || count <= 0 {
+			return nil, fmt.Errorf("socket: invalid keepalive_count %q", value)
+		}
+		o.KeepAlive.Enable = true
+		o.KeepAlive.Count = count
+	}
+	if value := query.Get("nodelay"); value != "" {
+		noDelay, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("socket: invalid nodelay %q", value)
+		}
+		o.Delay = !noDelay
+	}
+	if value := query.Get("fastopen"); value != "" {
+		fastOpen, err := strconv.Atoi(value)
+		if err != nil || fastOpen < 0 {
+			return nil, fmt.Errorf("socket: invalid fastopen %q", value)
+		}
+		o.FastOpen = fastOpen
+	}
+	if value := query.Get("defer_accept"); value != "" {
+		deferAccept, err := time.ParseDuration(value)
+		if err != nil || deferAccept < 0 {
+			return nil, fmt.Errorf("socket: invalid defer_accept %q", value)
+		}
+		o.DeferAccept = deferAccept
+	}
+	if value := query.Get("user_timeout"); value != "" {
+		userTimeout, err := time.ParseDuration(value)
+		if err != nil || userTimeout < 0 {
+			return nil, fmt.Errorf("socket: invalid user_timeout %q", value)
+		}
+		o.UserTimeout = userTimeout
+	}
+	if value := query.Get("rcvbuf"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("socket: invalid rcvbuf %q", value)
+		}
+		o.RecvBuffer = size
+	}
+	if value := query.Get("sndbuf"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("socket: invalid sndbuf %q", value)
+		}
+		o.SendBuffer = size
+	}
+	return &o, nil
+}
+
+// delayListener turns TCP_NODELAY off on accepted connections
+type delayListener struct {
+	net.Listener
+	options *SocketOptions
+}
+
+func (l *delayListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	// Best effort, the connection works either way
+	l.options.setConn(conn)
+	return conn, nil
+}
+
+// Unwrap returns the underlying listener
+func (l *delayListener) Unwrap() net.Listener {
+	return l.Listener
+}
@@ -0,0 +1,6 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+
+package socket
+
+// soReusePort is missing from the syscall package on linux
+const soReusePort = 0xf
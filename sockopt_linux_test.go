@@ -0,0 +1,53 @@
+package socket_test
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+)
+
+// getsockopt reads an integer socket option
+func getsockopt(t testing.TB, conn syscall.Conn, level, opt int) int {
+	t.Helper()
+	is := is.New(t)
+	raw, err := conn.SyscallConn()
+	is.NoErr(err)
+	var value int
+	var getErr error
+	is.NoErr(raw.Control(func(fd uintptr) {
+		value, getErr = syscall.GetsockoptInt(int(fd), level, opt)
+	}))
+	is.NoErr(getErr)
+	return value
+}
+
+func TestSocketOptionsListen(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen("127.0.0.1:0?backlog=16&rcvbuf=65536&sndbuf=65536&user_timeout=5s&defer_accept=2s&fastopen=16&keepalive=10s")
+	is.NoErr(err)
+	defer ln.Close()
+	tcp, ok := ln.(*net.TCPListener)
+	is.True(ok)
+	// Linux doubles the buffer sizes for bookkeeping
+	is.True(getsockopt(t, tcp, syscall.SOL_SOCKET, syscall.SO_RCVBUF) >= 65536)
+	is.True(getsockopt(t, tcp, syscall.SOL_SOCKET, syscall.SO_SNDBUF) >= 65536)
+	is.Equal(getsockopt(t, tcp, syscall.IPPROTO_TCP, 0x12), 5000) // TCP_USER_TIMEOUT
+	is.True(getsockopt(t, tcp, syscall.IPPROTO_TCP, syscall.TCP_DEFER_ACCEPT) > 0)
+}
+
+func TestSocketOptionsDial(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen("127.0.0.1:0")
+	is.NoErr(err)
+	defer ln.Close()
+	conn, err := socket.Dial(context.Background(), ln.Addr().String()+"?user_timeout=3s&nodelay=0")
+	is.NoErr(err)
+	defer conn.Close()
+	tcp := conn.(*net.TCPConn)
+	is.Equal(getsockopt(t, tcp, syscall.IPPROTO_TCP, 0x12), 3000) // TCP_USER_TIMEOUT
+	is.Equal(getsockopt(t, tcp, syscall.IPPROTO_TCP, syscall.TCP_NODELAY), 0)
+}
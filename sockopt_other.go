@@ -0,0 +1,6 @@
+//go:build unix && !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package socket
+
+// soReusePort isn't supported on this platform
+const soReusePort = 0
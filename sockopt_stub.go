@@ -0,0 +1,26 @@
+//go:build !unix && !windows
+
+package socket
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Socket options aren't supported on this platform, like js and wasip1
+const (
+	solSocket   = 0
+	soReuseAddr = 0
+	soReusePort = 0
+	soRcvbuf    = 0
+	soSndbuf    = 0
+	ipprotoTCP  = 0
+)
+
+func setsockoptInt(fd uintptr, level, opt, value int) error {
+	return fmt.Errorf("socket: socket options are not supported on %s", runtime.GOOS)
+}
+
+func listenFD(fd uintptr, backlog int) error {
+	return fmt.Errorf("socket: setting the backlog is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,60 @@
+package socket_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+)
+
+func TestSocketOptionsNoDelay(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen("127.0.0.1:0?nodelay=0&keepalive=off&rcvbuf=65536")
+	is.NoErr(err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+	conn, err := socket.Dial(context.Background(), ln.Addr().String()+"?nodelay=0&keepalive=10s&keepalive_count=3")
+	is.NoErr(err)
+	defer conn.Close()
+	_, ok := conn.(*net.TCPConn)
+	is.True(ok)
+	_, err = conn.Write([]byte("ping"))
+	is.NoErr(err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	is.NoErr(err)
+	is.Equal(string(buf), "ping")
+}
+
+func TestSocketOptionsInvalid(t *testing.T) {
+	is := is.New(t)
+	for _, query := range []string{
+		"backlog=0",
+		"keepalive=soon",
+		"keepalive_interval=-1s",
+		"keepalive_count=x",
+		"nodelay=maybe",
+		"fastopen=-1",
+		"defer_accept=x",
+		"user_timeout=x",
+		"rcvbuf=0",
+		"sndbuf=big",
+	} {
+		_, err := socket.Listen(":0?" + query)
+		is.True(err != nil) // expected an error
+	}
+	_, err := socket.Dial(context.Background(), "127.0.0.1:1?rcvbuf=0")
+	is.True(err != nil) // expected an error
+	_, err = socket.Transport("127.0.0.1:1?keepalive=soon")
+	is.True(err != nil) // expected an error
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package socket
+
+import "syscall"
+
+// Socket options that are defined on every unix
+const (
+	solSocket   = syscall.SOL_SOCKET
+	soReuseAddr = syscall.SO_REUSEADDR
+	soRcvbuf    = syscall.SO_RCVBUF
+	soSndbuf    = syscall.SO_SNDBUF
+	ipprotoTCP  = syscall.IPPROTO_TCP
+)
+
+func setsockoptInt(fd uintptr, level, opt, value int) error {
+	return syscall.SetsockoptInt(int(fd), level, opt, value)
+}
+
+func listenFD(fd uintptr, backlog int) error {
+	return syscall.Listen(int(fd), backlog)
+}
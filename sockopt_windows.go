@@ -0,0 +1,23 @@
+//go:build windows
+
+package socket
+
+import "syscall"
+
+// Socket options, where soReusePort isn't supported on windows
+const (
+	solSocket   = syscall.SOL_SOCKET
+	soReuseAddr = syscall.SO_REUSEADDR
+	soReusePort = 0
+	soRcvbuf    = syscall.SO_RCVBUF
+	soSndbuf    = syscall.SO_SNDBUF
+	ipprotoTCP  = syscall.IPPROTO_TCP
+)
+
+func setsockoptInt(fd uintptr, level, opt, value int) error {
+	return syscall.SetsockoptInt(syscall.Handle(fd), level, opt, value)
+}
+
+func listenFD(fd uintptr, backlog int) error {
+	return syscall.Listen(syscall.Handle(fd), backlog)
+}
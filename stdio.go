@@ -0,0 +1,165 @@
+package socket
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// errDone is returned by listeners that have no more connections to accept
+var errDone = errors.New("socket: listener is done")
+
+// listenStdio returns a listener with the single connection on stdin and
+// stdout, like inetd, systemd's StandardInput=socket or running a command over
+// ssh. Once that connection is closed, the listener is closed too.
+func listenStdio() (net.Listener, error) {
+	var conn net.Conn
+	// Launchers like inetd pass a connected socket. Only sockets go through
+	// FileConn, since it makes the descriptor non-blocking.
+	if isSocket(os.Stdin) {
+		sc, err := net.FileConn(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		conn = sc
+	} else {
+		in, out, err := stdioFiles()
+		if err != nil {
+			return nil, err
+		}
+		conn = newStdioConn(in, out)
+	}
+	// Format as stdio: even when stdin is a socket
+	return newSingleListener(conn, &url.URL{Scheme: "stdio"}), nil
+}
+
+// newSingleListener returns a listener that accepts the connection, then
+// waits for it to close. The url is where the connection came from.
+func newSingleListener(conn net.Conn, url *url.URL) *singleListener {
+	ln := &singleListener{
+		addr:   conn.LocalAddr(),
+		url:    url,
+		conns:  make(chan net.Conn, 1),
+		closed: make(chan struct{}),
+	}
+	ln.conns <- &singleListenerConn{Conn: conn, listener: ln}
+	return ln
+}
+
+// singleListener accepts one connection, then waits for it to close
+type singleListener struct {
+	addr   net.Addr
+	url    *url.URL
+	conns  chan net.Conn
+	once   sync.Once
+	closed chan struct{}
+	err    error
+}
+
+var _ net.Listener = (*singleListener)(nil)
+
+func (l *singleListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, l.err
+	}
+}
+
+// Close the listener. The connection is closed too if it was never accepted.
+func (l *singleListener) Close() error {
+	l.close(net.ErrClosed)
+	select {
+	case conn := <-l.conns:
+		conn.Close()
+	default:
+	}
+	return nil
+}
+
+// close the listener, returning err from Accept
+func (l *singleListener) close(err error) {
+	l.once.Do(func() {
+		l.err = err
+		close(l.closed)
+	})
+}
+
+func (l *singleListener) Addr() net.Addr {
+	return l.addr
+}
+
+func (l *singleListener) origin() *url.URL {
+	u := *l.url
+	return &u
+}
+
+// singleListenerConn finishes the listener once it's closed
+type singleListenerConn struct {
+	net.Conn
+	listener *singleListener
+}
+
+func (c *singleListenerConn) Close() error {
+	err := c.Conn.Close()
+	c.listener.close(errDone)
+	return err
+}
+
+// NetConn returns the underlying connection
+func (c *singleListenerConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// stdioConn reads from stdin and writes to stdout through an in-memory pipe.
+// Stdin and stdout stay blocking, since their flags are shared with the rest
+// of the process, and the pipe supports deadlines.
+type stdioConn struct {
+	*memConn
+	written chan struct{} // closed once the output is written
+}
+
+var _ net.Conn = (*stdioConn)(nil)
+
+func newStdioConn(in, out *os.File) *stdioConn {
+	conn, peer := memPipe(stdioAddr{})
+	c := &stdioConn{memConn: conn, written: make(chan struct{})}
+	// Reads from stdin can't be interrupted, so this stops at the first read
+	// after the connection is closed
+	go func() {
+		defer in.Close()
+		io.Copy(peer, in)
+		peer.CloseWrite()
+	}()
+	go func() {
+		defer close(c.written)
+		io.Copy(out, peer)
+		out.Close()
+		// Fail writes once stdout is gone
+		peer.Close()
+	}()
+	return c
+}
+
+// Close the connection after the remaining output is written
+func (c *stdioConn) Close() error {
+	err := c.memConn.Close()
+	<-c.written
+	return err
+}
+
+// isSocket is true when the file is a socket
+func isSocket(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// stdioAddr is the address of stdin and stdout
+type stdioAddr struct{}
+
+func (stdioAddr) Network() string { return "stdio" }
+func (stdioAddr) String() string  { return "stdio" }
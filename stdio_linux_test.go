@@ -0,0 +1,33 @@
+package socket_test
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+)
+
+// fileFlags returns the flags of the open file description behind the fd
+func fileFlags(t testing.TB, fd int) uintptr {
+	t.Helper()
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_GETFL, 0)
+	if errno != 0 {
+		t.Fatal(errno)
+	}
+	return flags
+}
+
+func TestListenStdioKeepsFlags(t *testing.T) {
+	is := is.New(t)
+	stdin, stdout := fileFlags(t, syscall.Stdin), fileFlags(t, syscall.Stdout)
+	ln, err := socket.Listen("stdio:")
+	is.NoErr(err)
+	// Stdin and stdout are shared with the rest of the process, so they stay
+	// blocking
+	is.Equal(fileFlags(t, syscall.Stdin), stdin)
+	is.Equal(fileFlags(t, syscall.Stdout), stdout)
+	is.NoErr(ln.Close())
+	is.Equal(fileFlags(t, syscall.Stdin), stdin)
+	is.Equal(fileFlags(t, syscall.Stdout), stdout)
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package socket
+
+import "os"
+
+// stdioFiles returns stdin and stdout
+func stdioFiles() (in, out *os.File, err error) {
+	return os.Stdin, os.Stdout, nil
+}
+
+// isConnected is false, since there's no way to tell
+func isConnected(int) bool {
+	return false
+}
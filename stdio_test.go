@@ -0,0 +1,72 @@
+package socket_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"os/exec"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/socket"
+	"github.com/matthewmueller/testchild"
+)
+
+func TestListenAndServeStdio(t *testing.T) {
+	parent := func(t testing.TB, cmd *exec.Cmd) {
+		is := is.New(t)
+		stdin, err := cmd.StdinPipe()
+		is.NoErr(err)
+		stdout, err := cmd.StdoutPipe()
+		is.NoErr(err)
+		is.NoErr(cmd.Start())
+
+		// Send two requests over the same connection
+		_, err = io.WriteString(stdin, "GET /a HTTP/1.1\r\nHost: stdio\r\n\r\nGET /b HTTP/1.1\r\nHost: stdio\r\n\r\n")
+		is.NoErr(err)
+
+		// Skip the test output that comes before the responses
+		reader := bufio.NewReader(stdout)
+		for {
+			peek, err := reader.Peek(5)
+			is.NoErr(err)
+			if string(peek) == "HTTP/" {
+				break
+			}
+			_, err = reader.ReadString('\n')
+			is.NoErr(err)
+		}
+		for _, path := range []string{"/a", "/b"} {
+			res, err := http.ReadResponse(reader, nil)
+			is.NoErr(err)
+			body, err := io.ReadAll(res.Body)
+			is.NoErr(err)
+			is.Equal(string(body), path)
+		}
+
+		// Hanging up ends the connection and the server exits on its own
+		is.NoErr(stdin.Close())
+		io.Copy(io.Discard, reader)
+		is.NoErr(cmd.Wait())
+	}
+
+	child := func(t testing.TB) {
+		is := is.New(t)
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.URL.Path))
+		})
+		is.NoErr(socket.ListenAndServe(context.Background(), "stdio:", handler))
+	}
+
+	testchild.Run(t, parent, child)
+}
+
+func TestListenStdioClose(t *testing.T) {
+	is := is.New(t)
+	ln, err := socket.Listen("stdio:")
+	is.NoErr(err)
+	is.NoErr(ln.Close())
+	_, err = ln.Accept()
+	is.True(err != nil) // expected the listener to be closed
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package socket
+
+import (
+	"os"
+	"syscall"
+)
+
+// stdioFiles duplicates stdin and stdout, so closing the connection leaves the
+// process's own stdin and stdout open
+func stdioFiles() (in, out *os.File, err error) {
+	in, err = dup(syscall.Stdin, "stdin")
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err = dup(syscall.Stdout, "stdout")
+	if err != nil {
+		in.Close()
+		return nil, nil, err
+	}
+	return in, out, nil
+}
+
+func dup(fd int, name string) (*os.File, error) {
+	dup, err := syscall.Dup(fd)
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(dup)
+	return os.NewFile(uintptr(dup), name), nil
+}
+
+// isConnected is true when the descriptor is a connected socket
+func isConnected(fd int) bool {
+	_, err := syscall.Getpeername(fd)
+	return err == nil
+}
@@ -0,0 +1,11 @@
+//go:build linux
+
+package socket
+
+// TCP options, some of which are missing from the syscall package
+const (
+	tcpDeferAccept     = 0x9
+	tcpUserTimeout     = 0x12
+	tcpFastOpen        = 0x17
+	tcpFastOpenConnect = 0x1e
+)
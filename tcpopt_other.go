@@ -0,0 +1,11 @@
+//go:build !linux
+
+package socket
+
+// TCP options that are only supported on linux
+const (
+	tcpDeferAccept     = 0
+	tcpUserTimeout     = 0
+	tcpFastOpen        = 0
+	tcpFastOpenConnect = 0
+)